@@ -0,0 +1,112 @@
+package access
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Rules decides which client IPs can access a resource. Denied
+// networks take precedence. If there are allowed networks, only
+// IPs inside them are allowed.
+type Rules struct {
+	Allow []*net.IPNet
+	Deny  []*net.IPNet
+}
+
+func (r Rules) Allows(ip net.IP) bool {
+	if ip == nil {
+		return len(r.Allow) == 0 && len(r.Deny) == 0
+	}
+	if contains(r.Deny, ip) {
+		return false
+	}
+	return len(r.Allow) == 0 || contains(r.Allow, ip)
+}
+
+// ClientIP returns the IP of the client that originated the request.
+// Forwarding headers are only taken into account when the peer is one
+// of the trusted proxies. In such case, the chain of forwarded addresses
+// is walked backwards, skipping the trusted proxies, until the first
+// untrusted address, which is the client one. The Forwarded header takes
+// precedence over X-Forwarded-For.
+func ClientIP(r *http.Request, trusted []*net.IPNet) string {
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !contains(trusted, net.ParseIP(peer)) {
+		return peer
+	}
+	chain := forwardedFor(r.Header.Values("Forwarded"))
+	if len(chain) == 0 {
+		chain = xForwardedFor(r.Header.Values("X-Forwarded-For"))
+	}
+	client := peer
+	for i := len(chain) - 1; i >= 0; i-- {
+		ip := net.ParseIP(chain[i])
+		if ip == nil {
+			break
+		}
+		client = ip.String()
+		if !contains(trusted, ip) {
+			break
+		}
+	}
+	return client
+}
+
+func contains(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func xForwardedFor(values []string) []string {
+	var chain []string
+	for _, v := range values {
+		for _, addr := range strings.Split(v, ",") {
+			chain = append(chain, strings.TrimSpace(addr))
+		}
+	}
+	return chain
+}
+
+// forwardedFor extracts the "for" parameters of the RFC 7239
+// Forwarded header values.
+func forwardedFor(values []string) []string {
+	var chain []string
+	for _, v := range values {
+		for _, element := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+				if len(kv) != 2 || !strings.EqualFold(kv[0], "for") {
+					continue
+				}
+				chain = append(chain, forwardedNode(kv[1]))
+			}
+		}
+	}
+	return chain
+}
+
+// forwardedNode strips quotes, brackets and ports from a
+// Forwarded node, i.e "[2001:db8::1]:4711" or 192.0.2.43:47011 .
+func forwardedNode(node string) string {
+	node = strings.Trim(node, `"`)
+	if strings.HasPrefix(node, "[") {
+		if end := strings.Index(node, "]"); end > 0 {
+			return node[1:end]
+		}
+	}
+	if host, _, err := net.SplitHostPort(node); err == nil {
+		return host
+	}
+	return node
+}
@@ -0,0 +1,66 @@
+// +build unit
+
+package access_test
+
+import (
+	"net"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.eloylp.dev/go-serve/access"
+)
+
+func nets(t *testing.T, cidrs ...string) []*net.IPNet {
+	var result []*net.IPNet
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+func TestRulesAllows(t *testing.T) {
+	r := access.Rules{
+		Allow: nets(t, "10.0.0.0/8"),
+		Deny:  nets(t, "10.0.0.0/24"),
+	}
+	assert.True(t, r.Allows(net.ParseIP("10.1.0.1")))
+	assert.False(t, r.Allows(net.ParseIP("10.0.0.1")))
+	assert.False(t, r.Allows(net.ParseIP("192.168.1.1")))
+	assert.False(t, r.Allows(nil))
+	assert.True(t, access.Rules{}.Allows(net.ParseIP("192.168.1.1")))
+}
+
+func TestClientIP(t *testing.T) {
+	trusted := nets(t, "10.0.0.0/8")
+	cases := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"untrusted peer headers are ignored", "192.168.1.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "192.168.1.1"},
+		{"trusted peer without headers", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"trusted peer", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "1.2.3.4"},
+		{"trusted chain is skipped", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, 5.6.7.8, 10.0.0.2"}, "5.6.7.8"},
+		{"all trusted", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"forwarded header", "10.0.0.1:1234", map[string]string{"Forwarded": `for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"`}, "2001:db8::1"},
+		{"forwarded takes precedence", "10.0.0.1:1234", map[string]string{"Forwarded": "for=192.0.2.60", "X-Forwarded-For": "1.2.3.4"}, "192.0.2.60"},
+		{"garbage stops the walk", "10.0.0.1:1234", map[string]string{"X-Forwarded-For": "1.2.3.4, unknown"}, "10.0.0.1"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = c.remote
+			for k, v := range c.headers {
+				req.Header.Set(k, v)
+			}
+			assert.Equal(t, c.want, access.ClientIP(req, trusted))
+		})
+	}
+}
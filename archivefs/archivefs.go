@@ -0,0 +1,367 @@
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	stdpath "path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrTooLarge is returned when the decompressed content of
+// a tarball exceeds the maximum size.
+var ErrTooLarge = errors.New("archivefs: archive too large")
+
+// Supported reports whether the name has the extension of a supported
+// archive, which are zip files and gzip compressed tarballs.
+func Supported(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, ".zip") || strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")
+}
+
+// Archive is a read only http.FileSystem of the members of an archive,
+// indexed when opened. Only regular files and directories are indexed,
+// parent directories included, even if the archive has no entries for
+// them. Tarballs are decompressed once to a temporary file, so members
+// are read from their offsets, as zip stored members, and seeking them
+// is fast. Zip compressed members are decompressed on every read.
+type Archive struct {
+	file    *os.File
+	modTime time.Time
+	members map[string]*member
+}
+
+type member struct {
+	info     os.FileInfo
+	children []os.FileInfo
+	offset   int64
+	zip      *zip.File
+}
+
+// Open opens and indexes the archive at the path. Tarballs whose
+// decompressed content exceeds the maximum size are rejected.
+func Open(path string, maxSize int64) (*Archive, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		return openZip(path)
+	}
+	return openTarGz(path, maxSize)
+}
+
+func openZip(path string) (*Archive, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	a, err := newArchive(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("archivefs: %w", err)
+	}
+	for _, zf := range zr.File {
+		info := zf.FileInfo()
+		switch {
+		case info.IsDir():
+			a.add(zf.Name, &member{info: info})
+		case info.Mode().IsRegular():
+			a.add(zf.Name, &member{info: info, zip: zf})
+		}
+	}
+	a.link()
+	return a, nil
+}
+
+func openTarGz(path string, maxSize int64) (*Archive, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+	gz, err := gzip.NewReader(src)
+	if err != nil {
+		return nil, fmt.Errorf("archivefs: %w", err)
+	}
+	spool, err := ioutil.TempFile("", "go-serve-archive-*.tar")
+	if err != nil {
+		return nil, err
+	}
+	// The spooled tarball is removed once closed, or right
+	// away in the systems where open files can be removed.
+	removed := os.Remove(spool.Name()) == nil
+	a, err := indexTar(spool, gz, maxSize)
+	if err != nil {
+		_ = spool.Close()
+		if !removed {
+			_ = os.Remove(spool.Name())
+		}
+		return nil, err
+	}
+	if info, err := src.Stat(); err == nil {
+		a.modTime = info.ModTime()
+		a.members["/"].info = dirInfo{name: "/", modTime: a.modTime}
+	}
+	return a, nil
+}
+
+func indexTar(spool *os.File, src io.Reader, maxSize int64) (*Archive, error) {
+	n, err := io.Copy(spool, io.LimitReader(src, maxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("archivefs: %w", err)
+	}
+	if n > maxSize {
+		return nil, ErrTooLarge
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	a, err := newArchive(spool)
+	if err != nil {
+		return nil, err
+	}
+	tr := tar.NewReader(spool)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("archivefs: %w", err)
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			a.add(h.Name, &member{info: h.FileInfo()})
+		case tar.TypeReg, tar.TypeRegA:
+			offset, err := spool.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return nil, err
+			}
+			a.add(h.Name, &member{info: h.FileInfo(), offset: offset})
+		}
+	}
+	a.link()
+	return a, nil
+}
+
+func newArchive(f *os.File) (*Archive, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	a := &Archive{file: f, modTime: info.ModTime(), members: map[string]*member{}}
+	a.members["/"] = &member{info: dirInfo{name: "/", modTime: a.modTime}}
+	return a, nil
+}
+
+// add indexes the member, creating its missing parent directories.
+// Later members replace the previous ones with the same name.
+func (a *Archive) add(name string, m *member) {
+	name = stdpath.Clean("/" + name)
+	if name == "/" {
+		return
+	}
+	if m.info.IsDir() {
+		m.info = dirInfo{name: stdpath.Base(name), modTime: m.info.ModTime()}
+	}
+	a.dir(stdpath.Dir(name))
+	a.members[name] = m
+}
+
+func (a *Archive) dir(name string) {
+	if _, ok := a.members[name]; ok {
+		return
+	}
+	a.dir(stdpath.Dir(name))
+	a.members[name] = &member{info: dirInfo{name: stdpath.Base(name), modTime: a.modTime}}
+}
+
+// link sets the children of the directories, sorted by name.
+func (a *Archive) link() {
+	for name, m := range a.members {
+		if name == "/" {
+			continue
+		}
+		parent := a.members[stdpath.Dir(name)]
+		parent.children = append(parent.children, m.info)
+	}
+	for _, m := range a.members {
+		sort.Slice(m.children, func(i, j int) bool { return m.children[i].Name() < m.children[j].Name() })
+	}
+}
+
+func (a *Archive) Open(name string) (http.File, error) {
+	m, ok := a.members[stdpath.Clean("/"+name)]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	if m.info.IsDir() {
+		return &dir{info: m.info, children: m.children}, nil
+	}
+	switch {
+	case m.zip == nil:
+		return &file{ReadSeeker: io.NewSectionReader(a.file, m.offset, m.info.Size()), info: m.info}, nil
+	case m.zip.Method == zip.Store:
+		offset, err := m.zip.DataOffset()
+		if err != nil {
+			return nil, err
+		}
+		return &file{ReadSeeker: io.NewSectionReader(a.file, offset, m.info.Size()), info: m.info}, nil
+	default:
+		r := &zipReader{f: m.zip, size: m.info.Size()}
+		return &file{ReadSeeker: r, info: m.info, closer: r}, nil
+	}
+}
+
+func (a *Archive) Close() error {
+	return a.file.Close()
+}
+
+type file struct {
+	io.ReadSeeker
+	info   os.FileInfo
+	closer io.Closer
+}
+
+func (f *file) Close() error {
+	if f.closer != nil {
+		return f.closer.Close()
+	}
+	return nil
+}
+
+func (f *file) Readdir(_ int) ([]os.FileInfo, error) {
+	return nil, fmt.Errorf("archivefs: %s is not a directory", f.info.Name())
+}
+
+func (f *file) Stat() (os.FileInfo, error) {
+	return f.info, nil
+}
+
+type dir struct {
+	info     os.FileInfo
+	children []os.FileInfo
+	pos      int
+}
+
+func (d *dir) Read(_ []byte) (int, error) {
+	return 0, fmt.Errorf("archivefs: %s is a directory", d.info.Name())
+}
+
+func (d *dir) Seek(_ int64, _ int) (int64, error) {
+	return 0, fmt.Errorf("archivefs: %s is a directory", d.info.Name())
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) Readdir(count int) ([]os.FileInfo, error) {
+	remaining := d.children[d.pos:]
+	if count <= 0 {
+		d.pos = len(d.children)
+		return remaining, nil
+	}
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(remaining) {
+		count = len(remaining)
+	}
+	d.pos += count
+	return remaining[:count], nil
+}
+
+func (d *dir) Stat() (os.FileInfo, error) {
+	return d.info, nil
+}
+
+type dirInfo struct {
+	name    string
+	modTime time.Time
+}
+
+func (i dirInfo) Name() string       { return i.name }
+func (i dirInfo) Size() int64        { return 0 }
+func (i dirInfo) Mode() os.FileMode  { return os.ModeDir | 0555 }
+func (i dirInfo) ModTime() time.Time { return i.modTime }
+func (i dirInfo) IsDir() bool        { return true }
+func (i dirInfo) Sys() interface{}   { return nil }
+
+// zipReader seeks a compressed zip member, decompressing it again
+// from the beginning only when seeking backwards.
+type zipReader struct {
+	f    *zip.File
+	size int64
+	pos  int64
+	rc   io.ReadCloser
+	read int64
+}
+
+func (z *zipReader) Read(p []byte) (int, error) {
+	if z.pos >= z.size {
+		return 0, io.EOF
+	}
+	if z.rc == nil || z.read > z.pos {
+		if err := z.Close(); err != nil {
+			return 0, err
+		}
+		rc, err := z.f.Open()
+		if err != nil {
+			return 0, err
+		}
+		z.rc, z.read = rc, 0
+	}
+	if z.read < z.pos {
+		n, err := io.CopyN(ioutil.Discard, z.rc, z.pos-z.read)
+		z.read += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := z.rc.Read(p)
+	z.read += int64(n)
+	z.pos += int64(n)
+	return n, err
+}
+
+func (z *zipReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += z.pos
+	case io.SeekEnd:
+		offset += z.size
+	default:
+		return 0, fmt.Errorf("archivefs: invalid whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("archivefs: negative position %d", offset)
+	}
+	z.pos = offset
+	return offset, nil
+}
+
+func (z *zipReader) Close() error {
+	if z.rc == nil {
+		return nil
+	}
+	err := z.rc.Close()
+	z.rc = nil
+	return err
+}
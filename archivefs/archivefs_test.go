@@ -0,0 +1,214 @@
+// +build unit
+
+package archivefs_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/archivefs"
+)
+
+var members = map[string]string{
+	"docs/index.html":      "<h1>Docs</h1>",
+	"docs/guide/intro.txt": "0123456789abcdefghij",
+	"README.md":            "readme",
+}
+
+func zipArchive(t *testing.T, method uint16) string {
+	path := filepath.Join(t.TempDir(), "site.zip")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	_, err = zw.Create("docs/")
+	require.NoError(t, err)
+	for name, content := range members {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		require.NoError(t, err)
+		_, err = io.WriteString(w, content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return path
+}
+
+func tarGzArchive(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "site.tar.gz")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "docs/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: time.Now()}))
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "docs/link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}))
+	for name, content := range members {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content)), ModTime: time.Now()}))
+		_, err = io.WriteString(tw, content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return path
+}
+
+func TestSupported(t *testing.T) {
+	assert.True(t, archivefs.Supported("v1.2.3.zip"))
+	assert.True(t, archivefs.Supported("site.tar.gz"))
+	assert.True(t, archivefs.Supported("SITE.TGZ"))
+	assert.False(t, archivefs.Supported("site.tar"))
+	assert.False(t, archivefs.Supported("site.gz"))
+}
+
+func TestArchive(t *testing.T) {
+	archives := map[string]string{
+		"zip deflated": zipArchive(t, zip.Deflate),
+		"zip stored":   zipArchive(t, zip.Store),
+		"tar.gz":       tarGzArchive(t),
+	}
+	for name, path := range archives {
+		t.Run(name, func(t *testing.T) {
+			a, err := archivefs.Open(path, 1<<20)
+			require.NoError(t, err)
+			defer a.Close()
+
+			f, err := a.Open("/docs/guide/intro.txt")
+			require.NoError(t, err)
+			info, err := f.Stat()
+			require.NoError(t, err)
+			assert.Equal(t, "intro.txt", info.Name())
+			assert.Equal(t, int64(20), info.Size())
+			_, err = f.Seek(10, io.SeekStart)
+			require.NoError(t, err)
+			rest, err := io.ReadAll(f)
+			require.NoError(t, err)
+			assert.Equal(t, "abcdefghij", string(rest))
+			_, err = f.Seek(-15, io.SeekEnd)
+			require.NoError(t, err)
+			part := make([]byte, 5)
+			_, err = io.ReadFull(f, part)
+			require.NoError(t, err)
+			assert.Equal(t, "56789", string(part))
+			require.NoError(t, f.Close())
+
+			root, err := a.Open("/")
+			require.NoError(t, err)
+			infos, err := root.Readdir(-1)
+			require.NoError(t, err)
+			require.Len(t, infos, 2)
+			assert.Equal(t, "README.md", infos[0].Name())
+			assert.Equal(t, "docs", infos[1].Name())
+			assert.True(t, infos[1].IsDir())
+
+			docs, err := a.Open("docs")
+			require.NoError(t, err)
+			infos, err = docs.Readdir(1)
+			require.NoError(t, err)
+			assert.Equal(t, "guide", infos[0].Name())
+			infos, err = docs.Readdir(1)
+			require.NoError(t, err)
+			assert.Equal(t, "index.html", infos[0].Name())
+			_, err = docs.Readdir(1)
+			assert.Equal(t, io.EOF, err)
+
+			_, err = a.Open("/docs/link")
+			assert.True(t, os.IsNotExist(err))
+			_, err = a.Open("/missing")
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestArchiveTooLarge(t *testing.T) {
+	_, err := archivefs.Open(tarGzArchive(t), 512)
+	assert.Equal(t, archivefs.ErrTooLarge, err)
+}
+
+func TestArchiveInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "site.zip")
+	require.NoError(t, os.WriteFile(path, []byte("not a zip"), 0600))
+	_, err := archivefs.Open(path, 1<<20)
+	assert.Error(t, err)
+}
+
+func TestCache(t *testing.T) {
+	c, err := archivefs.NewCache(1, 1<<20)
+	require.NoError(t, err)
+	path := zipArchive(t, zip.Deflate)
+
+	a, release, err := c.Open(path)
+	require.NoError(t, err)
+	again, releaseAgain, err := c.Open(path)
+	require.NoError(t, err)
+	assert.Same(t, a, again)
+	releaseAgain()
+
+	other, releaseOther, err := c.Open(tarGzArchive(t))
+	require.NoError(t, err)
+	defer releaseOther()
+	assert.NotSame(t, a, other)
+
+	// The evicted archive is still readable until released.
+	f, err := a.Open("/README.md")
+	require.NoError(t, err)
+	content, err := io.ReadAll(f)
+	require.NoError(t, err)
+	assert.Equal(t, "readme", string(content))
+	release()
+
+	require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Hour)))
+	reopened, releaseReopened, err := c.Open(path)
+	require.NoError(t, err)
+	defer releaseReopened()
+	assert.NotSame(t, a, reopened)
+}
+
+func TestCacheOpensConcurrentRequestsOnce(t *testing.T) {
+	c, err := archivefs.NewCache(1, 1<<20)
+	require.NoError(t, err)
+	path := tarGzArchive(t)
+
+	const requests = 10
+	archives := make(chan *archivefs.Archive, requests)
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a, release, err := c.Open(path)
+			if !assert.NoError(t, err) {
+				return
+			}
+			defer release()
+			archives <- a
+		}()
+	}
+	wg.Wait()
+	close(archives)
+	first := <-archives
+	for a := range archives {
+		assert.Same(t, first, a)
+	}
+}
+
+func TestCacheReturnsOpenErrors(t *testing.T) {
+	c, err := archivefs.NewCache(1, 1<<20)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "broken.zip")
+	require.NoError(t, os.WriteFile(path, []byte("not a zip"), 0644))
+
+	_, _, err = c.Open(path)
+	assert.Error(t, err)
+	_, _, err = c.Open(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,124 @@
+package archivefs
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Cache keeps the indexes of the recently used archives open, keyed
+// by their paths. Archives are opened again once they are modified, and
+// the evicted ones are closed once they are no longer in use.
+// It is safe for concurrent use. Archives are opened without holding the
+// cache lock, once at a time per path.
+type Cache struct {
+	mu       sync.Mutex
+	archives *lru.Cache
+	opening  map[string]*opening
+	maxSize  int64
+}
+
+// opening is an archive being opened, which the concurrent requests of
+// the same path wait for.
+type opening struct {
+	done chan struct{}
+	err  error
+}
+
+type cached struct {
+	archive *Archive
+	modTime time.Time
+	size    int64
+	refs    int
+	evicted bool
+}
+
+// NewCache returns a cache of up to size open archives, evicting the
+// least recently used ones. Tarballs whose decompressed content exceeds
+// the maximum size are not opened.
+func NewCache(size int, maxSize int64) (*Cache, error) {
+	c := &Cache{opening: map[string]*opening{}, maxSize: maxSize}
+	archives, err := lru.NewWithEvict(size, c.evict)
+	if err != nil {
+		return nil, fmt.Errorf("archivefs: %w", err)
+	}
+	c.archives = archives
+	return c, nil
+}
+
+// Open returns the archive at path, opening it if it is not cached, and
+// the function that releases it, which must be called once done with it.
+func (c *Cache) Open(path string) (*Archive, func(), error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		c.mu.Lock()
+		if v, ok := c.archives.Get(path); ok {
+			e := v.(*cached)
+			if e.modTime.Equal(info.ModTime()) && e.size == info.Size() {
+				release := c.release(e)
+				c.mu.Unlock()
+				return e.archive, release, nil
+			}
+			c.archives.Remove(path)
+		}
+		if o, ok := c.opening[path]; ok {
+			c.mu.Unlock()
+			<-o.done
+			if o.err != nil {
+				return nil, nil, o.err
+			}
+			continue
+		}
+		o := &opening{done: make(chan struct{})}
+		c.opening[path] = o
+		c.mu.Unlock()
+		return c.open(path, info, o)
+	}
+}
+
+// open opens the archive at path and caches it, waking up the requests
+// waiting for it.
+func (c *Cache) open(path string, info os.FileInfo, o *opening) (*Archive, func(), error) {
+	a, err := Open(path, c.maxSize)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.opening, path)
+	o.err = err
+	close(o.done)
+	if err != nil {
+		return nil, nil, err
+	}
+	e := &cached{archive: a, modTime: info.ModTime(), size: info.Size()}
+	c.archives.Add(path, e)
+	return a, c.release(e), nil
+}
+
+func (c *Cache) release(e *cached) func() {
+	e.refs++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			e.refs--
+			if e.evicted && e.refs == 0 {
+				_ = e.archive.Close()
+			}
+		})
+	}
+}
+
+// evict is called by the LRU cache, always with the mutex held.
+func (c *Cache) evict(_, v interface{}) {
+	e := v.(*cached)
+	e.evicted = true
+	if e.refs == 0 {
+		_ = e.archive.Close()
+	}
+}
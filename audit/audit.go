@@ -0,0 +1,76 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"go.eloylp.dev/go-serve/extract"
+)
+
+const (
+	OutcomeSuccess = "success"
+	OutcomeFailure = "failure"
+)
+
+// Event is the record of a write operation in the server.
+type Event struct {
+	Time        time.Time      `json:"time"`
+	Action      string         `json:"action"`
+	User        string         `json:"user,omitempty"`
+	ClientIP    string         `json:"client_ip"`
+	Path        string         `json:"path"`
+	ContentType string         `json:"content_type,omitempty"`
+	Bytes       int64          `json:"bytes"`
+	Files       []extract.File `json:"files,omitempty"`
+	SHA256      string         `json:"sha256,omitempty"`
+	Outcome     string         `json:"outcome"`
+	Error       string         `json:"error,omitempty"`
+}
+
+// Fail marks the event as failed because of the provided error.
+func (e *Event) Fail(err error) {
+	e.Outcome = OutcomeFailure
+	e.Error = err.Error()
+}
+
+// Sink receives the audit events.
+type Sink interface {
+	Record(e *Event) error
+}
+
+// JSONLines writes every event as a JSON document in its own line.
+type JSONLines struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func NewJSONLines(w io.Writer) *JSONLines {
+	return &JSONLines{w: w}
+}
+
+func (j *JSONLines) Record(e *Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if _, err := j.w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+	return nil
+}
+
+// OpenFile opens, or creates, the file at path in append mode, so it
+// can be used as the writer of a JSONLines sink.
+func OpenFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600) //nolint: gomnd
+	if err != nil {
+		return nil, fmt.Errorf("audit: %w", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,35 @@
+// +build unit
+
+package audit_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/audit"
+)
+
+func TestJSONLines(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	sink := audit.NewJSONLines(buf)
+
+	require.NoError(t, sink.Record(&audit.Event{Time: time.Now(), Action: "upload", Path: "/a", Outcome: audit.OutcomeSuccess}))
+	failed := &audit.Event{Time: time.Now(), Action: "upload", Path: "/b"}
+	failed.Fail(errors.New("boom"))
+	require.NoError(t, sink.Record(failed))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	var e audit.Event
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &e))
+	assert.Equal(t, "/b", e.Path)
+	assert.Equal(t, audit.OutcomeFailure, e.Outcome)
+	assert.Equal(t, "boom", e.Error)
+}
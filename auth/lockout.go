@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// Lockout tracks authentication failures per key, usually a user
+// name or a client IP. Once a key reaches the failures threshold, it
+// is locked out. Every consecutive lockout doubles its duration, up
+// to the maximum. Keys without failures during the maximum duration
+// are forgotten.
+type Lockout struct {
+	threshold int
+	duration  time.Duration
+	max       time.Duration
+	mu        sync.Mutex
+	entries   map[string]*failures
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type failures struct {
+	count       int
+	lockouts    int
+	last        time.Time
+	lockedUntil time.Time
+}
+
+func NewLockout(threshold int, duration, max time.Duration) *Lockout {
+	if max < duration {
+		max = duration
+	}
+	return &Lockout{
+		threshold: threshold,
+		duration:  duration,
+		max:       max,
+		entries:   map[string]*failures{},
+		now:       time.Now,
+	}
+}
+
+// Locked reports whether the key is locked out and for how long.
+func (l *Lockout) Locked(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, ok := l.entries[key]
+	if !ok {
+		return false, 0
+	}
+	remaining := f.lockedUntil.Sub(l.now())
+	return remaining > 0, remaining
+}
+
+// Fail records an authentication failure for the key. It reports
+// whether this failure triggered a lockout and its duration.
+func (l *Lockout) Fail(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.sweep(now)
+	f, ok := l.entries[key]
+	if !ok {
+		f = &failures{}
+		l.entries[key] = f
+	}
+	f.count++
+	f.last = now
+	if f.count < l.threshold {
+		return false, 0
+	}
+	d := l.duration << uint(f.lockouts)
+	if d > l.max || d <= 0 {
+		d = l.max
+	}
+	f.count = 0
+	f.lockouts++
+	f.lockedUntil = now.Add(d)
+	return true, d
+}
+
+// Succeed forgets the failures of the key.
+func (l *Lockout) Succeed(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.entries, key)
+}
+
+func (l *Lockout) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.duration {
+		return
+	}
+	l.lastSweep = now
+	for k, f := range l.entries {
+		if now.After(f.lockedUntil) && now.Sub(f.last) > l.max {
+			delete(l.entries, k)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+// +build unit
+
+package auth //nolint:testpackage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockout(t *testing.T) {
+	now := time.Now()
+	l := NewLockout(2, time.Minute, 3*time.Minute)
+	l.now = func() time.Time { return now }
+
+	locked, _ := l.Fail("user:alice")
+	assert.False(t, locked)
+	locked, d := l.Fail("user:alice")
+	assert.True(t, locked)
+	assert.Equal(t, time.Minute, d)
+
+	locked, remaining := l.Locked("user:alice")
+	assert.True(t, locked)
+	assert.Equal(t, time.Minute, remaining)
+	locked, _ = l.Locked("user:bob")
+	assert.False(t, locked)
+
+	// Consecutive lockouts double their duration, up to the maximum.
+	now = now.Add(time.Minute + time.Second)
+	locked, _ = l.Locked("user:alice")
+	assert.False(t, locked)
+	l.Fail("user:alice")
+	_, d = l.Fail("user:alice")
+	assert.Equal(t, 2*time.Minute, d)
+	l.Fail("user:alice")
+	_, d = l.Fail("user:alice")
+	assert.Equal(t, 3*time.Minute, d)
+
+	l.Succeed("user:alice")
+	locked, _ = l.Locked("user:alice")
+	assert.False(t, locked)
+}
+
+func TestLockoutForgetsIdleKeys(t *testing.T) {
+	now := time.Now()
+	l := NewLockout(5, time.Minute, time.Hour)
+	l.now = func() time.Time { return now }
+
+	l.Fail("ip:10.0.0.1")
+	now = now.Add(2 * time.Hour)
+	l.Fail("ip:10.0.0.2")
+	assert.NotContains(t, l.entries, "ip:10.0.0.1")
+	assert.Contains(t, l.entries, "ip:10.0.0.2")
+}
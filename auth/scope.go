@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"go.eloylp.dev/go-serve/glob"
+)
+
+const (
+	ActionRead     = "read"
+	ActionUpload   = "upload"
+	ActionDownload = "download"
+)
+
+// Scope grants an action over a path glob, expressed as "action:glob".
+// i.e "upload:/releases/*". A scope also covers everything below the
+// paths matched by its glob. Scopes only grant access to the default
+// host, unless they name a virtual host, as in "read@docs:/guides/*".
+type Scope string
+
+func ParseScope(value string) (Scope, error) {
+	parts := strings.SplitN(value, ":", 2)
+	if len(parts) != 2 {
+		return "", fmt.Errorf("scope %q: expected action[@host]:path format", value)
+	}
+	action := strings.SplitN(parts[0], "@", 2)
+	switch action[0] {
+	case ActionRead, ActionUpload, ActionDownload:
+	default:
+		return "", fmt.Errorf("scope %q: unknown action %q", value, action[0])
+	}
+	if len(action) == 2 && action[1] == "" {
+		return "", fmt.Errorf("scope %q: empty host", value)
+	}
+	if !strings.HasPrefix(parts[1], "/") {
+		return "", fmt.Errorf("scope %q: path must be absolute", value)
+	}
+	if _, err := glob.Compile(parts[1]); err != nil {
+		return "", fmt.Errorf("scope %q: %w", value, err)
+	}
+	return Scope(value), nil
+}
+
+func (s Scope) Action() string {
+	return strings.SplitN(s.prefix(), "@", 2)[0]
+}
+
+// Host returns the name of the virtual host of the scope,
+// which is empty for the default host.
+func (s Scope) Host() string {
+	parts := strings.SplitN(s.prefix(), "@", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+func (s Scope) prefix() string {
+	return strings.SplitN(string(s), ":", 2)[0]
+}
+
+func (s Scope) Path() string {
+	parts := strings.SplitN(string(s), ":", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	return parts[1]
+}
+
+// Allows reports whether the scope grants the action over the
+// provided path of the host, which is always considered relative
+// to its document root. The default host is the empty one.
+func (s Scope) Allows(host, action, target string) bool {
+	if s.Action() != action || s.Host() != host {
+		return false
+	}
+	r, err := glob.Compile(s.Path())
+	if err != nil {
+		return false
+	}
+	for p := path.Clean("/" + target); ; p = path.Dir(p) {
+		if r.MatchString(p) {
+			return true
+		}
+		if p == "/" {
+			return false
+		}
+	}
+}
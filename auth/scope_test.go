@@ -0,0 +1,50 @@
+// +build unit
+
+package auth_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/auth"
+)
+
+func TestParseScope(t *testing.T) {
+	s, err := auth.ParseScope("upload:/releases/*")
+	require.NoError(t, err)
+	assert.Equal(t, auth.ActionUpload, s.Action())
+	assert.Equal(t, "/releases/*", s.Path())
+	assert.Empty(t, s.Host())
+
+	s, err = auth.ParseScope("read@docs:/guides/*")
+	require.NoError(t, err)
+	assert.Equal(t, auth.ActionRead, s.Action())
+	assert.Equal(t, "docs", s.Host())
+	assert.Equal(t, "/guides/*", s.Path())
+
+	_, err = auth.ParseScope("read@:/guides")
+	assert.Error(t, err)
+	_, err = auth.ParseScope("delete:/releases")
+	assert.Error(t, err)
+	_, err = auth.ParseScope("upload")
+	assert.Error(t, err)
+	_, err = auth.ParseScope("upload:releases")
+	assert.Error(t, err)
+}
+
+func TestScopeAllows(t *testing.T) {
+	s := auth.Scope("upload:/releases/*")
+	assert.True(t, s.Allows("", auth.ActionUpload, "/releases/v1"))
+	assert.True(t, s.Allows("", auth.ActionUpload, "releases/v1/app.tar.gz"))
+	assert.False(t, s.Allows("", auth.ActionUpload, "/releases"))
+	assert.False(t, s.Allows("", auth.ActionUpload, "/releases/../etc"))
+	assert.False(t, s.Allows("", auth.ActionRead, "/releases/v1"))
+	assert.False(t, s.Allows("docs", auth.ActionUpload, "/releases/v1"))
+
+	s = auth.Scope("upload@docs:/releases/*")
+	assert.True(t, s.Allows("docs", auth.ActionUpload, "/releases/v1"))
+	assert.False(t, s.Allows("", auth.ActionUpload, "/releases/v1"))
+	assert.False(t, s.Allows("blog", auth.ActionUpload, "/releases/v1"))
+}
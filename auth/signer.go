@@ -0,0 +1,156 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	SignatureParam = "sig"
+	ExpiresParam   = "expires"
+	IPParam        = "ip"
+	SingleUseParam = "once"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid signature")
+	ErrExpiredSignature = errors.New("expired signature")
+	ErrSignatureIP      = errors.New("signature not valid for client IP")
+	ErrSignatureUsed    = errors.New("signature already used")
+)
+
+type SignOptions struct {
+	// Host is the name of the virtual host the URL is valid for,
+	// or empty for the default host.
+	Host      string
+	Expires   time.Time
+	IP        string
+	SingleUse bool
+}
+
+// Signer creates and verifies HMAC-SHA256 signed URLs. The signature
+// covers the host, the URL path and all its query parameters, so none
+// of them can be altered without invalidating it.
+type Signer struct {
+	key     []byte
+	mu      sync.Mutex
+	used    map[string]time.Time
+	claimed map[string]bool
+}
+
+func NewSigner(key []byte) *Signer {
+	return &Signer{
+		key:     key,
+		used:    map[string]time.Time{},
+		claimed: map[string]bool{},
+	}
+}
+
+// Sign returns the target URL with the signature parameters added.
+func (s *Signer) Sign(target string, opts SignOptions) (string, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("signer: %w", err)
+	}
+	if opts.Expires.IsZero() {
+		return "", errors.New("signer: expiration is required")
+	}
+	q := u.Query()
+	q.Del(SignatureParam)
+	q.Set(ExpiresParam, strconv.FormatInt(opts.Expires.Unix(), 10))
+	if opts.IP != "" {
+		q.Set(IPParam, opts.IP)
+	}
+	if opts.SingleUse {
+		q.Set(SingleUseParam, "1")
+	}
+	q.Set(SignatureParam, s.signature(opts.Host, u.Path, q))
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// Verify checks the signature of a request, made from clientIP, against
+// the provided host, path and query. Single use signatures are consumed
+// by a successful verification.
+func (s *Signer) Verify(host, path string, query url.Values, clientIP string) error {
+	done, err := s.Claim(host, path, query, clientIP)
+	if err != nil {
+		return err
+	}
+	done(true)
+	return nil
+}
+
+// Claim checks the signature like Verify, but single use signatures are
+// only reserved, so they cannot be used by concurrent requests, until the
+// returned function is called. It consumes them if used is true, or
+// releases them otherwise, so they can be used again.
+func (s *Signer) Claim(host, path string, query url.Values, clientIP string) (done func(used bool), err error) {
+	sig := query.Get(SignatureParam)
+	if sig == "" || !hmac.Equal([]byte(sig), []byte(s.signature(host, path, query))) {
+		return nil, ErrInvalidSignature
+	}
+	expires, err := strconv.ParseInt(query.Get(ExpiresParam), 10, 64)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+	expiration := time.Unix(expires, 0)
+	now := time.Now()
+	if now.After(expiration) {
+		return nil, ErrExpiredSignature
+	}
+	if ip := query.Get(IPParam); ip != "" && ip != clientIP {
+		return nil, ErrSignatureIP
+	}
+	if query.Get(SingleUseParam) == "" {
+		return func(bool) {}, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, exp := range s.used {
+		if now.After(exp) {
+			delete(s.used, k)
+		}
+	}
+	if _, ok := s.used[sig]; ok || s.claimed[sig] {
+		return nil, ErrSignatureUsed
+	}
+	s.claimed[sig] = true
+	var once sync.Once
+	return func(used bool) {
+		once.Do(func() {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			delete(s.claimed, sig)
+			if used {
+				s.used[sig] = expiration
+			}
+		})
+	}, nil
+}
+
+// IsSigned reports whether the query carries a signature.
+func IsSigned(query url.Values) bool {
+	return query.Get(SignatureParam) != ""
+}
+
+// signature returns the HMAC of the host followed by the path, which
+// cannot be mistaken for other ones, as paths start with a slash.
+func (s *Signer) signature(host, path string, query url.Values) string {
+	q := url.Values{}
+	for k, v := range query {
+		if k != SignatureParam {
+			q[k] = v
+		}
+	}
+	mac := hmac.New(sha256.New, s.key)
+	_, _ = mac.Write([]byte(host + path + "?" + q.Encode()))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
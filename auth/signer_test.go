@@ -0,0 +1,89 @@
+// +build unit
+
+package auth_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/auth"
+)
+
+func signedQuery(t *testing.T, s *auth.Signer, target string, opts auth.SignOptions) (string, url.Values) {
+	signed, err := s.Sign(target, opts)
+	require.NoError(t, err)
+	u, err := url.Parse(signed)
+	require.NoError(t, err)
+	return u.Path, u.Query()
+}
+
+func TestSignerVerify(t *testing.T) {
+	s := auth.NewSigner([]byte("secret"))
+	path, q := signedQuery(t, s, "/download?path=/releases", auth.SignOptions{Expires: time.Now().Add(time.Hour)})
+
+	assert.NoError(t, s.Verify("", path, q, "10.0.0.1"))
+	// Signed URLs can be reused by default.
+	assert.NoError(t, s.Verify("", path, q, "10.0.0.1"))
+
+	assert.Equal(t, auth.ErrInvalidSignature, s.Verify("", "/download", url.Values{"path": {"/releases"}}, "10.0.0.1"))
+	assert.Equal(t, auth.ErrInvalidSignature, s.Verify("", "/other", q, "10.0.0.1"))
+
+	tampered := url.Values{}
+	for k, v := range q {
+		tampered[k] = v
+	}
+	tampered.Set("path", "/")
+	assert.Equal(t, auth.ErrInvalidSignature, s.Verify("", path, tampered, "10.0.0.1"))
+
+	other := auth.NewSigner([]byte("other-secret"))
+	assert.Equal(t, auth.ErrInvalidSignature, other.Verify("", path, q, "10.0.0.1"))
+}
+
+func TestSignerVerifyHost(t *testing.T) {
+	s := auth.NewSigner([]byte("secret"))
+	path, q := signedQuery(t, s, "/static/file.txt", auth.SignOptions{Host: "docs", Expires: time.Now().Add(time.Hour)})
+
+	assert.NoError(t, s.Verify("docs", path, q, "10.0.0.1"))
+	assert.Equal(t, auth.ErrInvalidSignature, s.Verify("", path, q, "10.0.0.1"))
+	assert.Equal(t, auth.ErrInvalidSignature, s.Verify("blog", path, q, "10.0.0.1"))
+}
+
+func TestSignerExpiration(t *testing.T) {
+	s := auth.NewSigner([]byte("secret"))
+	path, q := signedQuery(t, s, "/static/file.txt", auth.SignOptions{Expires: time.Now().Add(-time.Second)})
+	assert.Equal(t, auth.ErrExpiredSignature, s.Verify("", path, q, "10.0.0.1"))
+}
+
+func TestSignerIPBinding(t *testing.T) {
+	s := auth.NewSigner([]byte("secret"))
+	path, q := signedQuery(t, s, "/static/file.txt", auth.SignOptions{Expires: time.Now().Add(time.Hour), IP: "10.0.0.1"})
+	assert.NoError(t, s.Verify("", path, q, "10.0.0.1"))
+	assert.Equal(t, auth.ErrSignatureIP, s.Verify("", path, q, "10.0.0.2"))
+}
+
+func TestSignerSingleUse(t *testing.T) {
+	s := auth.NewSigner([]byte("secret"))
+	path, q := signedQuery(t, s, "/static/file.txt", auth.SignOptions{Expires: time.Now().Add(time.Hour), SingleUse: true})
+	assert.NoError(t, s.Verify("", path, q, "10.0.0.1"))
+	assert.Equal(t, auth.ErrSignatureUsed, s.Verify("", path, q, "10.0.0.1"))
+}
+
+func TestSignerClaim(t *testing.T) {
+	s := auth.NewSigner([]byte("secret"))
+	path, q := signedQuery(t, s, "/static/file.txt", auth.SignOptions{Expires: time.Now().Add(time.Hour), SingleUse: true})
+
+	done, err := s.Claim("", path, q, "10.0.0.1")
+	require.NoError(t, err)
+	_, err = s.Claim("", path, q, "10.0.0.1")
+	assert.Equal(t, auth.ErrSignatureUsed, err, "claimed signatures cannot be used concurrently")
+	done(false)
+
+	done, err = s.Claim("", path, q, "10.0.0.1")
+	require.NoError(t, err, "released signatures can be used again")
+	done(true)
+	assert.Equal(t, auth.ErrSignatureUsed, s.Verify("", path, q, "10.0.0.1"))
+}
@@ -0,0 +1,217 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const TokenPrefix = "gst_"
+
+var (
+	ErrInvalidToken  = errors.New("invalid token")
+	ErrExpiredToken  = errors.New("expired token")
+	ErrTokenScope    = errors.New("token scopes do not allow this operation")
+	ErrTokenNotFound = errors.New("token not found")
+)
+
+// Token represents an API token issued by the server. Only the
+// SHA256 hash of the secret is kept, so the secret is just shown
+// once, at creation time.
+type Token struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Hash      string    `json:"hash"`
+	Scopes    []Scope   `json:"scopes"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+func (t *Token) Expired(now time.Time) bool {
+	return !t.ExpiresAt.IsZero() && now.After(t.ExpiresAt)
+}
+
+func (t *Token) Allows(host, action, target string) bool {
+	for _, s := range t.Scopes {
+		if s.Allows(host, action, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// TokenStore keeps the issued tokens in a JSON file. The file is
+// reloaded whenever it changes on disk, so tokens managed from the
+// CLI are picked up by a running server.
+type TokenStore struct {
+	path    string
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	tokens  []*Token
+}
+
+func NewTokenStore(path string) *TokenStore {
+	return &TokenStore{path: path}
+}
+
+// Create issues a new token with the provided scopes. A zero ttl
+// means the token never expires. The returned secret is the value
+// clients must send as bearer token.
+func (s *TokenStore) Create(name string, scopes []Scope, ttl time.Duration) (string, *Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return "", nil, err
+	}
+	id, err := randomHex(8) //nolint: gomnd
+	if err != nil {
+		return "", nil, err
+	}
+	secret, err := randomHex(32) //nolint: gomnd
+	if err != nil {
+		return "", nil, err
+	}
+	secret = TokenPrefix + secret
+	t := &Token{
+		ID:        id,
+		Name:      name,
+		Hash:      hashSecret(secret),
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if ttl > 0 {
+		t.ExpiresAt = t.CreatedAt.Add(ttl)
+	}
+	s.tokens = append(s.tokens, t)
+	if err := s.save(); err != nil {
+		return "", nil, err
+	}
+	return secret, t, nil
+}
+
+func (s *TokenStore) List() ([]Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	tokens := make([]Token, 0, len(s.tokens))
+	for _, t := range s.tokens {
+		tokens = append(tokens, *t)
+	}
+	return tokens, nil
+}
+
+func (s *TokenStore) Revoke(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return err
+	}
+	for i, t := range s.tokens {
+		if t.ID == id {
+			s.tokens = append(s.tokens[:i], s.tokens[i+1:]...)
+			return s.save()
+		}
+	}
+	return fmt.Errorf("tokens: %s: %w", id, ErrTokenNotFound)
+}
+
+// Authenticate checks the provided secret against the stored tokens,
+// returning the matching token only if it is not expired and its
+// scopes allow the action over the target path of the host.
+func (s *TokenStore) Authenticate(secret, host, action, target string) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	hash := []byte(hashSecret(secret))
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(hash, []byte(t.Hash)) != 1 {
+			continue
+		}
+		if t.Expired(time.Now()) {
+			return nil, ErrExpiredToken
+		}
+		if !t.Allows(host, action, target) {
+			return nil, ErrTokenScope
+		}
+		return t, nil
+	}
+	return nil, ErrInvalidToken
+}
+
+func (s *TokenStore) load() error {
+	info, err := os.Stat(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		s.tokens = nil
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("tokens: %w", err)
+	}
+	if info.ModTime().Equal(s.modTime) && info.Size() == s.size {
+		return nil
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return fmt.Errorf("tokens: %w", err)
+	}
+	var tokens []*Token
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return fmt.Errorf("tokens: %s: %w", s.path, err)
+	}
+	s.tokens = tokens
+	s.modTime = info.ModTime()
+	s.size = info.Size()
+	return nil
+}
+
+func (s *TokenStore) save() error {
+	data, err := json.MarshalIndent(s.tokens, "", "  ")
+	if err != nil {
+		return fmt.Errorf("tokens: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return fmt.Errorf("tokens: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("tokens: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("tokens: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return fmt.Errorf("tokens: %w", err)
+	}
+	// Force a reload on next access, so the in memory state always
+	// reflects what is persisted.
+	s.modTime = time.Time{}
+	return nil
+}
+
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("tokens: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
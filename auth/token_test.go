@@ -0,0 +1,53 @@
+// +build unit
+
+package auth_test
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/auth"
+)
+
+func TestTokenStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokens.json")
+	store := auth.NewTokenStore(path)
+
+	secret, token, err := store.Create("ci", []auth.Scope{"upload:/releases/*"}, time.Hour)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(secret, auth.TokenPrefix))
+	assert.NotContains(t, token.Hash, secret)
+
+	// A fresh store must see the persisted token.
+	other := auth.NewTokenStore(path)
+	got, err := other.Authenticate(secret, "", auth.ActionUpload, "/releases/v1")
+	require.NoError(t, err)
+	assert.Equal(t, token.ID, got.ID)
+
+	_, err = other.Authenticate(secret, "", auth.ActionUpload, "/other")
+	assert.True(t, errors.Is(err, auth.ErrTokenScope))
+	_, err = other.Authenticate(secret, "docs", auth.ActionUpload, "/releases/v1")
+	assert.True(t, errors.Is(err, auth.ErrTokenScope))
+	_, err = other.Authenticate("gst_bad", "", auth.ActionUpload, "/releases/v1")
+	assert.True(t, errors.Is(err, auth.ErrInvalidToken))
+
+	require.NoError(t, store.Revoke(token.ID))
+	_, err = other.Authenticate(secret, "", auth.ActionUpload, "/releases/v1")
+	assert.True(t, errors.Is(err, auth.ErrInvalidToken))
+	assert.True(t, errors.Is(store.Revoke(token.ID), auth.ErrTokenNotFound))
+}
+
+func TestTokenStoreExpiredToken(t *testing.T) {
+	store := auth.NewTokenStore(filepath.Join(t.TempDir(), "tokens.json"))
+	secret, _, err := store.Create("ci", []auth.Scope{"read:/"}, time.Nanosecond)
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	_, err = store.Authenticate(secret, "", auth.ActionRead, "/file.txt")
+	assert.True(t, errors.Is(err, auth.ErrExpiredToken))
+}
@@ -0,0 +1,18 @@
+package main
+
+import (
+	"fmt"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func runCommand(settings *config.Settings, name string, args []string) error {
+	switch name {
+	case "token":
+		return tokenCommand(settings, args)
+	case "sign":
+		return signCommand(settings, args)
+	default:
+		return fmt.Errorf("unknown command %q", name)
+	}
+}
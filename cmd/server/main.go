@@ -2,6 +2,7 @@ package main
 
 import (
 	"log"
+	"os"
 
 	"go.eloylp.dev/go-serve/config"
 	"go.eloylp.dev/go-serve/server"
@@ -12,6 +13,12 @@ func main() {
 	if err != nil {
 		log.Fatal(err)
 	}
+	if len(os.Args) > 1 {
+		if err := runCommand(settings, os.Args[1], os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 	s, err := server.New(settings)
 	if err != nil {
 		log.Fatal(err)
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"go.eloylp.dev/go-serve/auth"
+	"go.eloylp.dev/go-serve/config"
+)
+
+const signUsage = `usage: go-serve sign [-ttl <duration>] [-ip <address>] [-once] [-host <name>] [-base <url>] <path>
+
+  path is the public path to sign, i.e /static/releases/app.tar.gz or
+  /download?path=/releases`
+
+func signCommand(settings *config.Settings, args []string) error {
+	if settings.URLSigningKey == "" {
+		return errors.New("sign: GOSERVE_URL_SIGNING_KEY must be configured")
+	}
+	fs := flag.NewFlagSet("sign", flag.ContinueOnError)
+	ttl := fs.Duration("ttl", 24*time.Hour, "validity of the signed URL")
+	ip := fs.String("ip", "", "only accept the signed URL from this client IP")
+	once := fs.Bool("once", false, "the signed URL can only be used once")
+	host := fs.String("host", "", "name of the virtual host the signed URL is valid for. Empty means the default host")
+	base := fs.String("base", "", "scheme and host to prepend to the signed path, i.e https://example.com")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || !strings.HasPrefix(fs.Arg(0), "/") {
+		return errors.New(signUsage)
+	}
+	signed, err := auth.NewSigner([]byte(settings.URLSigningKey)).Sign(fs.Arg(0), auth.SignOptions{
+		Host:      *host,
+		Expires:   time.Now().Add(*ttl),
+		IP:        *ip,
+		SingleUse: *once,
+	})
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(os.Stdout, strings.TrimSuffix(*base, "/")+signed)
+	return nil
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"go.eloylp.dev/go-serve/auth"
+	"go.eloylp.dev/go-serve/config"
+)
+
+const tokenUsage = `usage: go-serve token <create|list|revoke> [flags]
+
+  create -name <name> -scope <action[@host]:path> [-scope ...] [-ttl <duration>]
+  list
+  revoke <id>`
+
+type scopeFlags []auth.Scope
+
+func (s *scopeFlags) String() string {
+	return fmt.Sprint(*s)
+}
+
+func (s *scopeFlags) Set(value string) error {
+	scope, err := auth.ParseScope(value)
+	if err != nil {
+		return err
+	}
+	*s = append(*s, scope)
+	return nil
+}
+
+func tokenCommand(settings *config.Settings, args []string) error {
+	if settings.TokensFile == "" {
+		return errors.New("token: GOSERVE_TOKENS_FILE must be configured")
+	}
+	if len(args) == 0 {
+		return errors.New(tokenUsage)
+	}
+	store := auth.NewTokenStore(settings.TokensFile)
+	switch args[0] {
+	case "create":
+		return createToken(store, args[1:])
+	case "list":
+		return listTokens(store)
+	case "revoke":
+		if len(args) != 2 { //nolint: gomnd
+			return errors.New(tokenUsage)
+		}
+		if err := store.Revoke(args[1]); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stdout, "token %s revoked\n", args[1])
+		return nil
+	default:
+		return errors.New(tokenUsage)
+	}
+}
+
+func createToken(store *auth.TokenStore, args []string) error {
+	fs := flag.NewFlagSet("token create", flag.ContinueOnError)
+	name := fs.String("name", "", "a description of the token holder")
+	ttl := fs.Duration("ttl", 0, "validity of the token. Zero means no expiration")
+	var scopes scopeFlags
+	fs.Var(&scopes, "scope", "an action[@host]:path scope, i.e upload:/releases/* or read@docs:/guides/*. Can be repeated")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if len(scopes) == 0 {
+		return errors.New("token: at least one -scope is required")
+	}
+	secret, token, err := store.Create(*name, scopes, *ttl)
+	if err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintf(os.Stdout, "id:      %s\ntoken:   %s\nexpires: %s\n", token.ID, secret, expiration(token))
+	return nil
+}
+
+func listTokens(store *auth.TokenStore) error {
+	tokens, err := store.List()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0) //nolint: gomnd
+	_, _ = fmt.Fprintln(w, "ID\tNAME\tSCOPES\tEXPIRES")
+	for i := range tokens {
+		t := &tokens[i]
+		scopes := make([]string, 0, len(t.Scopes))
+		for _, s := range t.Scopes {
+			scopes = append(scopes, string(s))
+		}
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.ID, t.Name, strings.Join(scopes, ","), expiration(t))
+	}
+	return w.Flush()
+}
+
+func expiration(t *auth.Token) string {
+	if t.ExpiresAt.IsZero() {
+		return "never"
+	}
+	return t.ExpiresAt.Format(time.RFC3339)
+}
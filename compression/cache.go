@@ -0,0 +1,74 @@
+package compression
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache keeps compressed contents in memory, evicting the least
+// recently used ones when their total size exceeds the maximum.
+// It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	max     int64
+	size    int64
+	entries *list.List
+	items   map[string]*list.Element
+}
+
+type entry struct {
+	key  string
+	data []byte
+}
+
+func NewCache(maxBytes int64) *Cache {
+	return &Cache{max: maxBytes, entries: list.New(), items: map[string]*list.Element{}}
+}
+
+// Max returns the maximum size in bytes of the cache.
+func (c *Cache) Max() int64 {
+	return c.max
+}
+
+// Get returns the content cached under the key, if any.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.entries.MoveToFront(e)
+	return e.Value.(*entry).data, true
+}
+
+// Put caches the content under the key. Contents bigger
+// than the maximum size of the cache are ignored.
+func (c *Cache) Put(key string, data []byte) {
+	if int64(len(data)) > c.max {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.remove(e)
+	}
+	c.items[key] = c.entries.PushFront(&entry{key: key, data: data})
+	c.size += int64(len(data))
+	for c.size > c.max {
+		c.remove(c.entries.Back())
+	}
+}
+
+// Size returns the total size in bytes of the cached contents.
+func (c *Cache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+func (c *Cache) remove(e *list.Element) {
+	en := c.entries.Remove(e).(*entry)
+	delete(c.items, en.key)
+	c.size -= int64(len(en.data))
+}
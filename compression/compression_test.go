@@ -0,0 +1,104 @@
+// +build unit
+
+package compression_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/compression"
+)
+
+func TestNewWriter(t *testing.T) {
+	content := strings.Repeat("go-serve ", 1000)
+	readers := map[string]func(r io.Reader) (io.Reader, error){
+		"br": func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"gzip": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		"zstd": func(r io.Reader) (io.Reader, error) {
+			return zstd.NewReader(r)
+		},
+	}
+	for _, encoding := range compression.Encodings {
+		t.Run(encoding, func(t *testing.T) {
+			var b bytes.Buffer
+			w, err := compression.NewWriter(&b, encoding)
+			require.NoError(t, err)
+			_, err = io.WriteString(w, content)
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+			assert.Less(t, b.Len(), len(content))
+			r, err := readers[encoding](&b)
+			require.NoError(t, err)
+			decoded, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Equal(t, content, string(decoded))
+		})
+	}
+}
+
+func TestNewWriterUnsupported(t *testing.T) {
+	_, err := compression.NewWriter(io.Discard, "compress")
+	assert.Error(t, err)
+	assert.False(t, compression.Supported("compress"))
+	assert.True(t, compression.Supported("zstd"))
+}
+
+func TestCompressible(t *testing.T) {
+	types := []string{"text/*", "application/json", "image/svg+xml"}
+	cases := map[string]bool{
+		"text/html; charset=utf-8": true,
+		"text/css":                 true,
+		"application/json":         true,
+		"image/svg+xml":            true,
+		"image/png":                false,
+		"application/tar+gzip":     false,
+		"":                         false,
+	}
+	for contentType, want := range cases {
+		assert.Equal(t, want, compression.Compressible(contentType, types), contentType)
+	}
+}
+
+func TestCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := compression.NewCache(10)
+	c.Put("a", []byte("aaaa"))
+	c.Put("b", []byte("bbbb"))
+	_, ok := c.Get("a")
+	require.True(t, ok)
+	c.Put("c", []byte("cccc"))
+	assert.Equal(t, int64(8), c.Size())
+	_, ok = c.Get("b")
+	assert.False(t, ok)
+	data, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "aaaa", string(data))
+	_, ok = c.Get("c")
+	assert.True(t, ok)
+}
+
+func TestCacheIgnoresBigContents(t *testing.T) {
+	c := compression.NewCache(4)
+	c.Put("a", []byte("aaaaa"))
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, int64(0), c.Size())
+}
+
+func TestCacheReplacesKeys(t *testing.T) {
+	c := compression.NewCache(10)
+	c.Put("a", []byte("aaaa"))
+	c.Put("a", []byte("aa"))
+	data, _ := c.Get("a")
+	assert.Equal(t, "aa", string(data))
+	assert.Equal(t, int64(2), c.Size())
+}
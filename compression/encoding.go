@@ -0,0 +1,43 @@
+package compression
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Encodings are the supported content encodings,
+// in order of preference.
+var Encodings = []string{"br", "zstd", "gzip"}
+
+// brotliLevel trades some compression ratio for speed,
+// as responses are compressed on the fly.
+const brotliLevel = 5
+
+// Supported reports whether the content encoding is supported.
+func Supported(encoding string) bool {
+	for _, e := range Encodings {
+		if e == encoding {
+			return true
+		}
+	}
+	return false
+}
+
+// NewWriter returns a writer that compresses to w with the content
+// encoding. Closing it flushes the pending data, but does not close w.
+func NewWriter(w io.Writer, encoding string) (io.WriteCloser, error) {
+	switch encoding {
+	case "br":
+		return brotli.NewWriterLevel(w, brotliLevel), nil
+	case "zstd":
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	case "gzip":
+		return gzip.NewWriter(w), nil
+	default:
+		return nil, fmt.Errorf("compression: unsupported encoding %q", encoding)
+	}
+}
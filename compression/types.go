@@ -0,0 +1,22 @@
+package compression
+
+import (
+	"mime"
+	"strings"
+)
+
+// Compressible reports whether the media type of the content type
+// matches any of the patterns, which can end with a "/*" wildcard.
+func Compressible(contentType string, patterns []string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == mediaType || (strings.HasSuffix(p, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
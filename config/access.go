@@ -0,0 +1,60 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+// IPNets is a list of networks, decoded from a comma separated list of
+// CIDR blocks or single IP addresses.
+type IPNets []*net.IPNet
+
+func (n *IPNets) Decode(value string) error {
+	var nets IPNets
+	for _, v := range strings.Split(value, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return fmt.Errorf("invalid IP address %q", v)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 8 * net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(v)
+		if err != nil {
+			return err
+		}
+		nets = append(nets, ipNet)
+	}
+	*n = nets
+	return nil
+}
+
+// IPRules holds the networks allowed and denied to access an
+// endpoint class.
+type IPRules struct {
+	Allow IPNets
+	Deny  IPNets
+}
+
+func (r IPRules) Enabled() bool {
+	return len(r.Allow) > 0 || len(r.Deny) > 0
+}
+
+type AccessSettings struct {
+	Files    IPRules
+	Upload   IPRules
+	Download IPRules
+	Metrics  IPRules
+	Status   IPRules
+}
@@ -0,0 +1,25 @@
+// +build unit
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func TestIPNets_Decode(t *testing.T) {
+	var n config.IPNets
+	err := n.Decode("10.0.0.0/8, 192.168.1.10,2001:db8::/32")
+	require.NoError(t, err)
+	require.Len(t, n, 3)
+	assert.Equal(t, "10.0.0.0/8", n[0].String())
+	assert.Equal(t, "192.168.1.10/32", n[1].String())
+	assert.Equal(t, "2001:db8::/32", n[2].String())
+
+	assert.Error(t, n.Decode("10.0.0.0/33"))
+	assert.Error(t, n.Decode("not-an-ip"))
+}
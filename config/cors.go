@@ -0,0 +1,25 @@
+package config
+
+import "time"
+
+// CORSPolicy is the cross origin resource sharing policy of an endpoint
+// class. It is disabled unless there are allowed origins. Methods default
+// to the ones of the endpoint class.
+type CORSPolicy struct {
+	AllowOrigins     []string      `split_words:"true"`
+	AllowMethods     []string      `split_words:"true"`
+	AllowHeaders     []string      `split_words:"true"`
+	ExposeHeaders    []string      `split_words:"true"`
+	AllowCredentials bool          `split_words:"true"`
+	MaxAge           time.Duration `split_words:"true"`
+}
+
+func (p CORSPolicy) Enabled() bool {
+	return len(p.AllowOrigins) > 0
+}
+
+type CORSSettings struct {
+	Files    CORSPolicy
+	Upload   CORSPolicy
+	Download CORSPolicy
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// HeaderRule sets, appends or removes a response header on the
+// requests whose paths match the pattern. Patterns are globs, unless
+// prefixed with "~", which makes them regular expressions.
+type HeaderRule struct {
+	Pattern string
+	Action  string
+	Name    string
+	Value   string
+}
+
+// HeaderRules is a list of header rules, one per line, as header values
+// like CSP ones can contain semicolons and commas. Each rule is a pattern,
+// followed by the set, append or remove action and the header, i.e:
+//
+//	/static/assets/** set Cache-Control: immutable, max-age=31536000
+//	index.html set Cache-Control: no-cache
+//	/** remove X-Powered-By
+//
+// Empty lines and lines starting with # are ignored.
+type HeaderRules []HeaderRule
+
+func (r *HeaderRules) Decode(value string) error {
+	var rules HeaderRules
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern, rest := cutField(line)
+		action, header := cutField(rest)
+		rule := HeaderRule{Pattern: pattern, Action: action}
+		switch action {
+		case "set", "append":
+			i := strings.Index(header, ":")
+			if i < 1 {
+				return fmt.Errorf("invalid header in rule %q", line)
+			}
+			rule.Name, rule.Value = strings.TrimSpace(header[:i]), strings.TrimSpace(header[i+1:])
+		case "remove":
+			if header == "" || strings.ContainsAny(header, ": ") {
+				return fmt.Errorf("invalid header in rule %q", line)
+			}
+			rule.Name = header
+		default:
+			return fmt.Errorf("invalid action %q in rule %q", action, line)
+		}
+		rules = append(rules, rule)
+	}
+	*r = rules
+	return nil
+}
+
+// cutField returns the first space separated field of
+// the value and the rest of it, without leading spaces.
+func cutField(value string) (string, string) {
+	i := strings.IndexFunc(value, unicode.IsSpace)
+	if i < 0 {
+		return value, ""
+	}
+	return value[:i], strings.TrimLeftFunc(value[i:], unicode.IsSpace)
+}
@@ -0,0 +1,37 @@
+// +build unit
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func TestHeaderRules_Decode(t *testing.T) {
+	var r config.HeaderRules
+	err := r.Decode(`
+		# Assets never change.
+		/static/assets/** set Cache-Control: immutable, max-age=31536000
+		index.html   set   Cache-Control: no-cache
+
+		~^/static/.*$ append Content-Security-Policy: default-src 'self'; img-src *
+		/** remove X-Powered-By
+	`)
+	require.NoError(t, err)
+	assert.Equal(t, config.HeaderRules{
+		{Pattern: "/static/assets/**", Action: "set", Name: "Cache-Control", Value: "immutable, max-age=31536000"},
+		{Pattern: "index.html", Action: "set", Name: "Cache-Control", Value: "no-cache"},
+		{Pattern: "~^/static/.*$", Action: "append", Name: "Content-Security-Policy", Value: "default-src 'self'; img-src *"},
+		{Pattern: "/**", Action: "remove", Name: "X-Powered-By"},
+	}, r)
+
+	assert.Error(t, r.Decode("/** replace X-Foo: bar"))
+	assert.Error(t, r.Decode("/** set X-Foo"))
+	assert.Error(t, r.Decode("/** set"))
+	assert.Error(t, r.Decode("/** remove X-Foo: bar"))
+	assert.Error(t, r.Decode("/** remove"))
+}
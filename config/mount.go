@@ -0,0 +1,46 @@
+package config
+
+import (
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+// Mount serves a directory under a URL prefix, besides the document
+// root, with its own listings policy and authorizations. Mounts are read
+// only unless stated, otherwise they accept uploads at their prefix.
+type Mount struct {
+	Name                string        `ignored:"true"`
+	Prefix              string        `required:"true"`
+	Dir                 string        `required:"true"`
+	ReadOnly            bool          `default:"true" split_words:"true"`
+	ListingDisabled     []string      `split_words:"true"`
+	ReadAuthorizations  Authorization `split_words:"true"`
+	WriteAuthorizations Authorization `split_words:"true"`
+}
+
+// Mounts is decoded from a comma separated list of mount names. Each
+// mount is configured by the variables prefixed with its name, like
+// GOSERVE_MOUNT_RELEASES_DIR for the "releases" one.
+type Mounts []*Mount
+
+func (m *Mounts) Decode(value string) error {
+	names, err := settingsNames(value)
+	if err != nil {
+		return err
+	}
+	var mounts Mounts
+	for _, name := range names {
+		mount := &Mount{
+			Name:                name,
+			ReadAuthorizations:  Authorization{},
+			WriteAuthorizations: Authorization{},
+		}
+		if err := envconfig.Process("GOSERVE_MOUNT_"+strings.ToUpper(name), mount); err != nil {
+			return err
+		}
+		mounts = append(mounts, mount)
+	}
+	*m = mounts
+	return nil
+}
@@ -0,0 +1,43 @@
+// +build unit
+
+package config_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func TestMounts_Decode(t *testing.T) {
+	env := map[string]string{
+		"GOSERVE_MOUNT_RELEASES_PREFIX":           "/releases",
+		"GOSERVE_MOUNT_RELEASES_DIR":              "/mnt/artifacts",
+		"GOSERVE_MOUNT_RELEASES_LISTING_DISABLED": "private/**",
+		"GOSERVE_MOUNT_INBOX_PREFIX":              "/inbox",
+		"GOSERVE_MOUNT_INBOX_DIR":                 "/srv/inbox",
+		"GOSERVE_MOUNT_INBOX_READ_ONLY":           "false",
+	}
+	for k, v := range env {
+		require.NoError(t, os.Setenv(k, v))
+		defer os.Unsetenv(k)
+	}
+	var mounts config.Mounts
+	require.NoError(t, mounts.Decode("releases,inbox"))
+	require.Len(t, mounts, 2)
+	assert.Equal(t, &config.Mount{
+		Name:                "releases",
+		Prefix:              "/releases",
+		Dir:                 "/mnt/artifacts",
+		ReadOnly:            true,
+		ListingDisabled:     []string{"private/**"},
+		ReadAuthorizations:  config.Authorization{},
+		WriteAuthorizations: config.Authorization{},
+	}, mounts[0])
+	assert.False(t, mounts[1].ReadOnly)
+
+	assert.Error(t, mounts.Decode("missing"))
+}
@@ -3,6 +3,8 @@ package config
 import (
 	"io"
 	"time"
+
+	"go.eloylp.dev/go-serve/storage"
 )
 
 type Option func(cfg *Settings)
@@ -39,12 +41,104 @@ func WithDocRoot(docRoot string) Option {
 	}
 }
 
+func WithStorage(s storage.Storage) Option {
+	return func(cfg *Settings) {
+		cfg.Storage = s
+	}
+}
+
 func WithDocRootPrefix(prefix string) Option {
 	return func(cfg *Settings) {
 		cfg.Prefix = prefix
 	}
 }
 
+func WithMounts(mounts ...*Mount) Option {
+	return func(cfg *Settings) {
+		cfg.Mounts = mounts
+	}
+}
+
+func WithSymlinks(policy string) Option {
+	return func(cfg *Settings) {
+		cfg.Symlinks = policy
+	}
+}
+
+func WithHiddenFiles(patterns ...string) Option {
+	return func(cfg *Settings) {
+		cfg.HiddenFiles = patterns
+	}
+}
+
+func WithListingTemplate(path string) Option {
+	return func(cfg *Settings) {
+		cfg.ListingTemplate = path
+	}
+}
+
+func WithListingDisabled(patterns ...string) Option {
+	return func(cfg *Settings) {
+		cfg.ListingDisabled = patterns
+	}
+}
+
+func WithSPAPrefixes(prefixes ...string) Option {
+	return func(cfg *Settings) {
+		cfg.SPAPrefixes = prefixes
+	}
+}
+
+func WithErrorPages(dir string) Option {
+	return func(cfg *Settings) {
+		cfg.ErrorPages = true
+		cfg.ErrorPagesDir = dir
+	}
+}
+
+func WithPrecompressed(enabled bool) Option {
+	return func(cfg *Settings) {
+		cfg.Precompressed = enabled
+	}
+}
+
+func WithETags(enabled bool) Option {
+	return func(cfg *Settings) {
+		cfg.ETags = enabled
+	}
+}
+
+func WithCompression(minSize, cacheSize int64, encodings ...string) Option {
+	return func(cfg *Settings) {
+		cfg.Compression = true
+		cfg.CompressionMinSize = minSize
+		cfg.CompressionCacheSize = cacheSize
+		if len(encodings) > 0 {
+			cfg.CompressionEncodings = encodings
+		}
+	}
+}
+
+func WithHeaderRules(rules HeaderRules) Option {
+	return func(cfg *Settings) {
+		cfg.HeaderRules = rules
+	}
+}
+
+func WithRedirects(rules string, fromFile bool) Option {
+	return func(cfg *Settings) {
+		cfg.Redirects = rules
+		cfg.RedirectsFile = fromFile
+	}
+}
+
+func WithArchives(enabled bool, maxSize int64) Option {
+	return func(cfg *Settings) {
+		cfg.Archives = enabled
+		cfg.ArchivesMaxSize = maxSize
+	}
+}
+
 func WithUploadEndpoint(path string) Option {
 	return func(cfg *Settings) {
 		cfg.UploadEndpoint = path
@@ -57,6 +151,12 @@ func WithDownLoadEndpoint(path string) Option {
 	}
 }
 
+func WithVirtualHosts(hosts ...*VirtualHost) Option {
+	return func(cfg *Settings) {
+		cfg.VirtualHosts = hosts
+	}
+}
+
 func WithLoggerLevel(level string) Option {
 	return func(cfg *Settings) {
 		cfg.Logger.Level = level
@@ -87,6 +187,102 @@ func WithWriteAuthorizations(auth Authorization) Option {
 	}
 }
 
+func WithTokensFile(path string) Option {
+	return func(cfg *Settings) {
+		cfg.TokensFile = path
+	}
+}
+
+func WithAuditLog(path string) Option {
+	return func(cfg *Settings) {
+		cfg.AuditLog = path
+	}
+}
+
+func WithUploadPolicy(policy UploadPolicy) Option {
+	return func(cfg *Settings) {
+		cfg.UploadPolicy = policy
+	}
+}
+
+func WithUploadScanner(address string, timeout time.Duration, failOpen bool) Option {
+	return func(cfg *Settings) {
+		cfg.UploadScanner = address
+		cfg.UploadScanTimeout = timeout
+		cfg.UploadScanFailOpen = failOpen
+	}
+}
+
+func WithExtract(extract *ExtractSettings) Option {
+	return func(cfg *Settings) {
+		cfg.Extract = extract
+	}
+}
+
+func WithUploadStagingDir(dir string) Option {
+	return func(cfg *Settings) {
+		cfg.UploadStagingDir = dir
+	}
+}
+
+func WithURLSigningKey(key string) Option {
+	return func(cfg *Settings) {
+		cfg.URLSigningKey = key
+	}
+}
+
+func WithAuthLockout(threshold int, duration, max time.Duration) Option {
+	return func(cfg *Settings) {
+		cfg.AuthLockoutThreshold = threshold
+		cfg.AuthLockoutDuration = duration
+		cfg.AuthLockoutMaxDuration = max
+	}
+}
+
+func WithTrustedProxies(nets IPNets) Option {
+	return func(cfg *Settings) {
+		cfg.TrustedProxies = nets
+	}
+}
+
+func WithAccess(access *AccessSettings) Option {
+	return func(cfg *Settings) {
+		cfg.Access = access
+	}
+}
+
+func WithCORS(cors *CORSSettings) Option {
+	return func(cfg *Settings) {
+		cfg.CORS = cors
+	}
+}
+
+func WithRateLimitIP(rate float64, burst int) Option {
+	return func(cfg *Settings) {
+		cfg.RateLimitIP = rate
+		cfg.RateLimitIPBurst = burst
+	}
+}
+
+func WithRateLimitUser(rate float64, burst int) Option {
+	return func(cfg *Settings) {
+		cfg.RateLimitUser = rate
+		cfg.RateLimitUserBurst = burst
+	}
+}
+
+func WithMaxConcurrentDownloads(max int) Option {
+	return func(cfg *Settings) {
+		cfg.MaxConcurrentDownloads = max
+	}
+}
+
+func WithMaxConcurrentUploads(max int) Option {
+	return func(cfg *Settings) {
+		cfg.MaxConcurrentUploads = max
+	}
+}
+
 func WithMetricsEnabled(enabled bool) Option {
 	return func(cfg *Settings) {
 		cfg.MetricsEnabled = enabled
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// rule is the generic form of the rules accepted by several settings.
+// Rules are separated by semicolons. Each one starts with a path
+// pattern, followed by space separated key=value,value fields, i.e:
+//
+//	/ deny_ext=.html,.js; /site allow_mime=text/*,image/*
+type rule struct {
+	pattern string
+	fields  map[string][]string
+}
+
+func parseRules(value string, keys ...string) ([]rule, error) {
+	var rules []rule
+	for _, raw := range strings.Split(value, ";") {
+		parts := strings.Fields(raw)
+		if len(parts) == 0 {
+			continue
+		}
+		r := rule{pattern: parts[0], fields: map[string][]string{}}
+		for _, f := range parts[1:] {
+			kv := strings.SplitN(f, "=", 2)             //nolint: gomnd
+			if len(kv) != 2 || !contains(keys, kv[0]) { //nolint: gomnd
+				return nil, fmt.Errorf("invalid field %q in rule %q", f, strings.TrimSpace(raw))
+			}
+			for _, v := range strings.Split(kv[1], ",") {
+				if v != "" {
+					r.fields[kv[0]] = append(r.fields[kv[0]], v)
+				}
+			}
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -8,25 +8,70 @@ import (
 
 	"github.com/kelseyhightower/envconfig"
 	"github.com/sirupsen/logrus"
+
+	"go.eloylp.dev/go-serve/storage"
 )
 
 type Settings struct {
-	ListenAddr                    string          `default:"0.0.0.0:8080" split_words:"true"`
-	DocRoot                       string          `required:"." split_words:"true"`
-	Prefix                        string          `default:"/static" split_words:"true"`
-	UploadEndpoint                string          `split_words:"true"`
-	DownloadEndpoint              string          `split_words:"true"`
-	ShutdownTimeout               time.Duration   `default:"5s" split_words:"true"`
-	Logger                        *LoggerSettings `split_words:"true"`
-	ReadTimeout                   time.Duration   `default:"0s" split_words:"true"`
-	WriteTimeout                  time.Duration   `default:"0s" split_words:"true"`
-	ReadAuthorizations            Authorization   `split_words:"true"`
-	WriteAuthorizations           Authorization   `split_words:"true"`
-	MetricsEnabled                bool            `default:"true" split_words:"true"`
-	MetricsPath                   string          `default:"/metrics" split_words:"true"`
-	MetricsListenAddr             string          `split_words:"true"`
-	MetricsRequestDurationBuckets []float64       `split_words:"true"`
-	MetricsSizeBuckets            []float64       `split_words:"true"`
+	ListenAddr                    string           `default:"0.0.0.0:8080" split_words:"true"`
+	DocRoot                       string           `required:"." split_words:"true"`
+	Storage                       storage.Storage  `ignored:"true"`
+	Prefix                        string           `default:"/static" split_words:"true"`
+	Mounts                        Mounts           `split_words:"true"`
+	Symlinks                      string           `default:"inside"`
+	HiddenFiles                   []string         `default:".*,!.well-known,CVS" split_words:"true"`
+	ListingTemplate               string           `split_words:"true"`
+	ListingDisabled               []string         `split_words:"true"`
+	SPAPrefixes                   []string         `envconfig:"SPA_PREFIXES"`
+	ErrorPages                    bool             `split_words:"true"`
+	ErrorPagesDir                 string           `split_words:"true"`
+	Precompressed                 bool             `default:"false"`
+	ETags                         bool             `default:"false" envconfig:"ETAGS"`
+	Compression                   bool             `split_words:"true"`
+	CompressionEncodings          []string         `default:"br,zstd,gzip" split_words:"true"`
+	CompressionTypes              []string         `default:"text/*,application/javascript,application/json,application/manifest+json,application/wasm,application/xml,image/svg+xml" split_words:"true"`
+	CompressionMinSize            int64            `default:"1024" split_words:"true"`
+	CompressionCacheSize          int64            `default:"67108864" split_words:"true"`
+	HeaderRules                   HeaderRules      `split_words:"true"`
+	Redirects                     string           `split_words:"true"`
+	RedirectsFile                 bool             `split_words:"true"`
+	Archives                      bool             `split_words:"true"`
+	ArchivesMaxSize               int64            `default:"1073741824" split_words:"true"`
+	UploadEndpoint                string           `split_words:"true"`
+	DownloadEndpoint              string           `split_words:"true"`
+	VirtualHosts                  VirtualHosts     `envconfig:"VHOSTS"`
+	ShutdownTimeout               time.Duration    `default:"5s" split_words:"true"`
+	Logger                        *LoggerSettings  `split_words:"true"`
+	ReadTimeout                   time.Duration    `default:"0s" split_words:"true"`
+	WriteTimeout                  time.Duration    `default:"0s" split_words:"true"`
+	ReadAuthorizations            Authorization    `split_words:"true"`
+	WriteAuthorizations           Authorization    `split_words:"true"`
+	TokensFile                    string           `split_words:"true"`
+	AuditLog                      string           `split_words:"true"`
+	UploadPolicy                  UploadPolicy     `split_words:"true"`
+	UploadStagingDir              string           `split_words:"true"`
+	UploadScanner                 string           `split_words:"true"`
+	UploadScanTimeout             time.Duration    `default:"30s" split_words:"true"`
+	UploadScanFailOpen            bool             `split_words:"true"`
+	Extract                       *ExtractSettings `split_words:"true"`
+	URLSigningKey                 string           `envconfig:"URL_SIGNING_KEY"`
+	AuthLockoutThreshold          int              `split_words:"true"`
+	AuthLockoutDuration           time.Duration    `default:"1m" split_words:"true"`
+	AuthLockoutMaxDuration        time.Duration    `default:"1h" split_words:"true"`
+	TrustedProxies                IPNets           `split_words:"true"`
+	Access                        *AccessSettings  `split_words:"true"`
+	CORS                          *CORSSettings    `envconfig:"CORS"`
+	RateLimitIP                   float64          `split_words:"true"`
+	RateLimitIPBurst              int              `split_words:"true"`
+	RateLimitUser                 float64          `split_words:"true"`
+	RateLimitUserBurst            int              `split_words:"true"`
+	MaxConcurrentDownloads        int              `split_words:"true"`
+	MaxConcurrentUploads          int              `split_words:"true"`
+	MetricsEnabled                bool             `default:"true" split_words:"true"`
+	MetricsPath                   string           `default:"/metrics" split_words:"true"`
+	MetricsListenAddr             string           `split_words:"true"`
+	MetricsRequestDurationBuckets []float64        `split_words:"true"`
+	MetricsSizeBuckets            []float64        `split_words:"true"`
 }
 
 type LoggerSettings struct {
@@ -48,6 +93,8 @@ func defaultSettings() *Settings {
 		ListenAddr:      "0.0.0.0:8080",
 		DocRoot:         ".",
 		Prefix:          "/static",
+		Symlinks:        "inside",
+		HiddenFiles:     []string{".*", "!.well-known", "CVS"},
 		ShutdownTimeout: time.Second,
 		Logger: &LoggerSettings{
 			Level:  logrus.InfoLevel.String(),
@@ -58,10 +105,28 @@ func defaultSettings() *Settings {
 		WriteTimeout:                  0,
 		WriteAuthorizations:           Authorization{},
 		ReadAuthorizations:            Authorization{},
+		Access:                        &AccessSettings{},
+		CORS:                          &CORSSettings{},
+		UploadScanTimeout:             30 * time.Second,
+		CompressionEncodings:          []string{"br", "zstd", "gzip"},
+		CompressionTypes:              []string{"text/*", "application/javascript", "application/json", "application/manifest+json", "application/wasm", "application/xml", "image/svg+xml"},
+		CompressionMinSize:            1024,
+		CompressionCacheSize:          64 << 20,
+		ArchivesMaxSize:               1 << 30,
+		AuthLockoutDuration:           time.Minute,
+		AuthLockoutMaxDuration:        time.Hour,
 		MetricsEnabled:                true,
 		MetricsPath:                   "/metrics",
 		MetricsRequestDurationBuckets: []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
 		MetricsSizeBuckets:            []float64{64, 256, 1024, 4096, 16_384, 65_536, 262_144, 1_048_576, 4_194_304, 16_777_216},
+		Extract: &ExtractSettings{
+			Symlinks:      "reject",
+			Hardlinks:     "sanitize",
+			Devices:       "skip",
+			SpecialBits:   "sanitize",
+			AbsolutePaths: "sanitize",
+			Umask:         0022,
+		},
 	}
 	return s
 }
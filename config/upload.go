@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// UploadRule restricts the files that can be uploaded under a path
+// prefix of the document root.
+type UploadRule struct {
+	Prefix          string
+	AllowExtensions []string
+	DenyExtensions  []string
+	AllowMIMETypes  []string
+	DenyMIMETypes   []string
+}
+
+// UploadPolicy is a list of upload rules. Each rule is a path prefix followed
+// by any of the allow_ext, deny_ext, allow_mime and deny_mime fields, i.e:
+//
+//	/ deny_ext=.html,.js; /site allow_ext=.html,.css allow_mime=text/*
+type UploadPolicy []UploadRule
+
+func (p *UploadPolicy) Decode(value string) error {
+	rules, err := parseRules(value, "allow_ext", "deny_ext", "allow_mime", "deny_mime")
+	if err != nil {
+		return err
+	}
+	var policy UploadPolicy
+	for _, r := range rules {
+		if !strings.HasPrefix(r.pattern, "/") {
+			return fmt.Errorf("upload rule prefix %q must start with /", r.pattern)
+		}
+		policy = append(policy, UploadRule{
+			Prefix:          r.pattern,
+			AllowExtensions: r.fields["allow_ext"],
+			DenyExtensions:  r.fields["deny_ext"],
+			AllowMIMETypes:  r.fields["allow_mime"],
+			DenyMIMETypes:   r.fields["deny_mime"],
+		})
+	}
+	*p = policy
+	return nil
+}
+
+// ExtractAction is what to do with a risky archive entry. One
+// of "allow", "reject", "skip" or "sanitize".
+type ExtractAction string
+
+func (a *ExtractAction) Decode(value string) error {
+	switch value {
+	case "allow", "reject", "skip", "sanitize":
+		*a = ExtractAction(value)
+		return nil
+	default:
+		return fmt.Errorf("invalid extract action %q", value)
+	}
+}
+
+// FileMode is decoded from octal notation, i.e "0022".
+type FileMode os.FileMode
+
+func (m *FileMode) Decode(value string) error {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid file mode %q", value)
+	}
+	*m = FileMode(mode)
+	return nil
+}
+
+// ExtractSettings holds the policy applied to the entries
+// of the uploaded archives.
+type ExtractSettings struct {
+	Symlinks      ExtractAction `default:"reject"`
+	Hardlinks     ExtractAction `default:"sanitize"`
+	Devices       ExtractAction `default:"skip"`
+	SpecialBits   ExtractAction `default:"sanitize" split_words:"true"`
+	AbsolutePaths ExtractAction `default:"sanitize" split_words:"true"`
+	Umask         FileMode      `default:"0022"`
+	ForceMode     FileMode      `split_words:"true"`
+}
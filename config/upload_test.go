@@ -0,0 +1,26 @@
+// +build unit
+
+package config_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func TestUploadPolicy_Decode(t *testing.T) {
+	var p config.UploadPolicy
+	err := p.Decode("/ deny_ext=.html,.js;  /site allow_ext=.html,.css allow_mime=text/*,image/png ;")
+	require.NoError(t, err)
+	assert.Equal(t, config.UploadPolicy{
+		{Prefix: "/", DenyExtensions: []string{".html", ".js"}},
+		{Prefix: "/site", AllowExtensions: []string{".html", ".css"}, AllowMIMETypes: []string{"text/*", "image/png"}},
+	}, p)
+
+	assert.Error(t, p.Decode("/ unknown=.html"))
+	assert.Error(t, p.Decode("/ deny_ext"))
+	assert.Error(t, p.Decode("site deny_ext=.html"))
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+)
+
+// VirtualHost serves the requests for its host names from its own
+// document root, with its own prefix, endpoints, authorizations and
+// header rules. The rest of the settings are shared by all hosts.
+type VirtualHost struct {
+	Name                string        `ignored:"true"`
+	Hosts               []string      `required:"true"`
+	DocRoot             string        `required:"true" split_words:"true"`
+	Prefix              string        `default:"/static"`
+	UploadEndpoint      string        `split_words:"true"`
+	DownloadEndpoint    string        `split_words:"true"`
+	ReadAuthorizations  Authorization `split_words:"true"`
+	WriteAuthorizations Authorization `split_words:"true"`
+	HeaderRules         HeaderRules   `split_words:"true"`
+}
+
+// VirtualHosts is decoded from a comma separated list of virtual host
+// names. Each virtual host is configured by the variables prefixed with
+// its name, like GOSERVE_VHOST_DOCS_HOSTS for the "docs" one.
+type VirtualHosts []*VirtualHost
+
+func (v *VirtualHosts) Decode(value string) error {
+	names, err := settingsNames(value)
+	if err != nil {
+		return err
+	}
+	var hosts VirtualHosts
+	for _, name := range names {
+		vh := &VirtualHost{
+			Name:                name,
+			ReadAuthorizations:  Authorization{},
+			WriteAuthorizations: Authorization{},
+		}
+		if err := envconfig.Process("GOSERVE_VHOST_"+strings.ToUpper(name), vh); err != nil {
+			return err
+		}
+		hosts = append(hosts, vh)
+	}
+	*v = hosts
+	return nil
+}
+
+var settingsName = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// settingsNames splits the comma separated list of names of the
+// settings, like virtual hosts, configured by their own variables.
+func settingsNames(value string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !settingsName.MatchString(name) {
+			return nil, fmt.Errorf("invalid name %q", name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,46 @@
+// +build unit
+
+package config_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func TestVirtualHosts_Decode(t *testing.T) {
+	env := map[string]string{
+		"GOSERVE_VHOST_DOCS_HOSTS":                    "docs.example,www.docs.example",
+		"GOSERVE_VHOST_DOCS_DOC_ROOT":                 "/srv/docs",
+		"GOSERVE_VHOST_ARTIFACTS_HOSTS":               "artifacts.example",
+		"GOSERVE_VHOST_ARTIFACTS_DOC_ROOT":            "/srv/artifacts",
+		"GOSERVE_VHOST_ARTIFACTS_PREFIX":              "/files",
+		"GOSERVE_VHOST_ARTIFACTS_UPLOAD_ENDPOINT":     "/upload",
+		"GOSERVE_VHOST_ARTIFACTS_READ_AUTHORIZATIONS": "dXNlcjpoYXNo",
+	}
+	for k, v := range env {
+		require.NoError(t, os.Setenv(k, v))
+		defer os.Unsetenv(k)
+	}
+	var hosts config.VirtualHosts
+	require.NoError(t, hosts.Decode("docs, artifacts"))
+	require.Len(t, hosts, 2)
+	assert.Equal(t, &config.VirtualHost{
+		Name:                "docs",
+		Hosts:               []string{"docs.example", "www.docs.example"},
+		DocRoot:             "/srv/docs",
+		Prefix:              "/static",
+		ReadAuthorizations:  config.Authorization{},
+		WriteAuthorizations: config.Authorization{},
+	}, hosts[0])
+	assert.Equal(t, "/files", hosts[1].Prefix)
+	assert.Equal(t, "/upload", hosts[1].UploadEndpoint)
+	assert.Equal(t, config.Authorization{"user": "hash"}, hosts[1].ReadAuthorizations)
+
+	assert.Error(t, hosts.Decode("missing"))
+	assert.Error(t, hosts.Decode("docs.example"))
+}
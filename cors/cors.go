@@ -0,0 +1,160 @@
+package cors
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.eloylp.dev/go-serve/glob"
+)
+
+// Policy decides which cross origin requests browsers can make to a
+// resource. Origins can be "*", to allow any of them, or globs like
+// "https://*.example.com". Any origin cannot be allowed along with
+// credentials, as any site could then read the responses with the
+// credentials of their visitors. Headers can be "*" too, to allow any
+// requested header, which are echoed back when credentials are allowed,
+// as browsers reject the wildcard then.
+type Policy struct {
+	AllowOrigins     []string
+	AllowMethods     []string
+	AllowHeaders     []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CORS applies a policy to requests.
+type CORS struct {
+	policy     Policy
+	origins    []*regexp.Regexp
+	anyOrigin  bool
+	anyHeader  bool
+	methods    map[string]bool
+	headers    map[string]bool
+	exposed    string
+	allMethods string
+}
+
+func New(p Policy) (*CORS, error) {
+	c := &CORS{
+		policy:     p,
+		methods:    map[string]bool{},
+		headers:    map[string]bool{},
+		exposed:    strings.Join(p.ExposeHeaders, ", "),
+		allMethods: strings.Join(p.AllowMethods, ", "),
+	}
+	for _, o := range p.AllowOrigins {
+		if o == "*" && p.AllowCredentials {
+			return nil, fmt.Errorf("cors: any origin cannot be allowed along with credentials")
+		}
+		if o == "*" {
+			c.anyOrigin = true
+			continue
+		}
+		re, err := glob.Compile(strings.ToLower(o))
+		if err != nil {
+			return nil, fmt.Errorf("cors: invalid origin %q: %w", o, err)
+		}
+		c.origins = append(c.origins, re)
+	}
+	for _, m := range p.AllowMethods {
+		c.methods[strings.ToUpper(m)] = true
+	}
+	for _, h := range p.AllowHeaders {
+		if h == "*" {
+			c.anyHeader = true
+			continue
+		}
+		c.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	return c, nil
+}
+
+// IsPreflight reports whether the request is a CORS preflight one.
+func IsPreflight(r *http.Request) bool {
+	return r.Method == http.MethodOptions && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
+
+// Preflight sets the response headers of a preflight request, reporting
+// whether the requested origin, method and headers are allowed.
+func (c *CORS) Preflight(r *http.Request, h http.Header) bool {
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	origin := r.Header.Get("Origin")
+	if !c.allowsOrigin(origin) || !c.methods[strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))] {
+		return false
+	}
+	requested := requestedHeaders(r)
+	for _, name := range requested {
+		if !c.anyHeader && !c.headers[name] {
+			return false
+		}
+	}
+	c.setOrigin(origin, h)
+	h.Set("Access-Control-Allow-Methods", c.allMethods)
+	if len(requested) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requested, ", "))
+	}
+	if c.policy.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.policy.MaxAge.Seconds())))
+	}
+	return true
+}
+
+// Apply sets the response headers of an actual cross origin request,
+// if its origin is allowed.
+func (c *CORS) Apply(r *http.Request, h http.Header) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	h.Add("Vary", "Origin")
+	if !c.allowsOrigin(origin) {
+		return
+	}
+	c.setOrigin(origin, h)
+	if c.exposed != "" {
+		h.Set("Access-Control-Expose-Headers", c.exposed)
+	}
+}
+
+func (c *CORS) allowsOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, re := range c.origins {
+		if re.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORS) setOrigin(origin string, h http.Header) {
+	if c.anyOrigin {
+		h.Set("Access-Control-Allow-Origin", "*")
+		return
+	}
+	h.Set("Access-Control-Allow-Origin", origin)
+	if c.policy.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func requestedHeaders(r *http.Request) []string {
+	var result []string
+	for _, v := range r.Header.Values("Access-Control-Request-Headers") {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				result = append(result, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return result
+}
@@ -0,0 +1,104 @@
+// +build unit
+
+package cors_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/cors"
+)
+
+func preflight(origin, method, headers string) *http.Request {
+	r := httptest.NewRequest(http.MethodOptions, "/upload", nil)
+	r.Header.Set("Origin", origin)
+	r.Header.Set("Access-Control-Request-Method", method)
+	if headers != "" {
+		r.Header.Set("Access-Control-Request-Headers", headers)
+	}
+	return r
+}
+
+func TestPreflight(t *testing.T) {
+	c, err := cors.New(cors.Policy{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowMethods:     []string{"POST"},
+		AllowHeaders:     []string{"Authorization", "content-type"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	})
+	require.NoError(t, err)
+
+	r := preflight("https://app.example.com", "POST", "authorization, Content-Type")
+	assert.True(t, cors.IsPreflight(r))
+	h := http.Header{}
+	assert.True(t, c.Preflight(r, h))
+	assert.Equal(t, "https://app.example.com", h.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", h.Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "POST", h.Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Authorization, Content-Type", h.Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", h.Get("Access-Control-Max-Age"))
+	assert.Contains(t, h.Values("Vary"), "Origin")
+
+	assert.True(t, c.Preflight(preflight("https://docs.example.org", "POST", ""), http.Header{}))
+
+	denied := []*http.Request{
+		preflight("https://evil.example.com", "POST", ""),
+		preflight("http://docs.example.org", "POST", ""),
+		preflight("https://docs.example.org.evil.com", "POST", ""),
+		preflight("https://app.example.com", "DELETE", ""),
+		preflight("https://app.example.com", "POST", "X-Custom"),
+	}
+	for _, r := range denied {
+		h := http.Header{}
+		assert.False(t, c.Preflight(r, h))
+		assert.Empty(t, h.Get("Access-Control-Allow-Origin"))
+	}
+}
+
+func TestApply(t *testing.T) {
+	c, err := cors.New(cors.Policy{
+		AllowOrigins:  []string{"*"},
+		AllowMethods:  []string{"GET"},
+		ExposeHeaders: []string{"ETag", "Content-Length"},
+	})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/static/app.js", nil)
+	h := http.Header{}
+	c.Apply(r, h)
+	assert.Empty(t, h)
+	assert.False(t, cors.IsPreflight(r))
+
+	r.Header.Set("Origin", "https://app.example.com")
+	c.Apply(r, h)
+	assert.Equal(t, "*", h.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "ETag, Content-Length", h.Get("Access-Control-Expose-Headers"))
+	assert.Empty(t, h.Get("Access-Control-Allow-Credentials"))
+}
+
+func TestAnyOriginWithCredentialsIsRejected(t *testing.T) {
+	_, err := cors.New(cors.Policy{AllowOrigins: []string{"https://app.example.com", "*"}, AllowMethods: []string{"GET"}, AllowCredentials: true})
+	assert.Error(t, err)
+}
+
+func TestApplyWithCredentialsEchoesOrigin(t *testing.T) {
+	c, err := cors.New(cors.Policy{AllowOrigins: []string{"https://*.example.com"}, AllowHeaders: []string{"*"}, AllowMethods: []string{"GET"}, AllowCredentials: true})
+	require.NoError(t, err)
+
+	r := httptest.NewRequest(http.MethodGet, "/static/app.js", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	h := http.Header{}
+	c.Apply(r, h)
+	assert.Equal(t, "https://app.example.com", h.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", h.Get("Access-Control-Allow-Credentials"))
+
+	h = http.Header{}
+	assert.True(t, c.Preflight(preflight("https://app.example.com", "GET", "X-Anything"), h))
+	assert.Equal(t, "X-Anything", h.Get("Access-Control-Allow-Headers"))
+}
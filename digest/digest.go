@@ -0,0 +1,93 @@
+package digest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// Cache keeps the SHA-256 sums of file contents, keyed by the device,
+// inode, modification time and size of the files, so they are computed
+// again only when files change, even if they keep their paths. On
+// systems without inodes, the path of the file is used instead.
+// It is safe for concurrent use.
+type Cache struct {
+	sums *lru.Cache
+}
+
+// NewCache returns a cache of the sums of up to size files,
+// evicting the least recently used ones.
+func NewCache(size int) (*Cache, error) {
+	sums, err := lru.New(size)
+	if err != nil {
+		return nil, fmt.Errorf("digest: %w", err)
+	}
+	return &Cache{sums: sums}, nil
+}
+
+// Lookup returns the sum of the file at path, with the provided
+// info, only if it was already computed.
+func (c *Cache) Lookup(path string, info os.FileInfo) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	sum, ok := c.sums.Get(keyOf(path, info))
+	if !ok {
+		return "", false
+	}
+	return sum.(string), true
+}
+
+// Sum returns the sum of the file at path, with the provided info,
+// computing it from its content, read from r, if not cached.
+func (c *Cache) Sum(path string, info os.FileInfo, r io.Reader) (string, error) {
+	if sum, ok := c.Lookup(path, info); ok {
+		return sum, nil
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", fmt.Errorf("digest: %w", err)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	c.sums.Add(keyOf(path, info), sum)
+	return sum, nil
+}
+
+// Precompute computes and caches the sum of the file at
+// path, if it is a regular file. Symlinks are not followed.
+func (c *Cache) Precompute(path string) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return fmt.Errorf("digest: %w", err)
+	}
+	if !info.Mode().IsRegular() {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("digest: %w", err)
+	}
+	defer f.Close()
+	if info, err = f.Stat(); err != nil {
+		return fmt.Errorf("digest: %w", err)
+	}
+	_, err = c.Sum(path, info, f)
+	return err
+}
+
+// ETag returns the strong entity tag of a sum.
+func ETag(sum string) string {
+	return `"` + sum + `"`
+}
+
+type key struct {
+	dev     uint64
+	ino     uint64
+	path    string
+	modTime int64
+	size    int64
+}
@@ -0,0 +1,110 @@
+// +build unit
+
+package digest_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/digest"
+)
+
+func sha256Of(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) {
+	return 0, errors.New("read error")
+}
+
+func TestCacheSum(t *testing.T) {
+	c, err := digest.NewCache(10)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0600))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+
+	_, ok := c.Lookup(path, info)
+	assert.False(t, ok)
+	sum, err := c.Sum(path, info, strings.NewReader("content"))
+	require.NoError(t, err)
+	assert.Equal(t, sha256Of("content"), sum)
+
+	// Cached sums are not computed again.
+	sum, err = c.Sum(path, info, failingReader{})
+	require.NoError(t, err)
+	assert.Equal(t, sha256Of("content"), sum)
+	sum, ok = c.Lookup(path, info)
+	assert.True(t, ok)
+	assert.Equal(t, sha256Of("content"), sum)
+}
+
+func TestCacheDetectsChanges(t *testing.T) {
+	c, err := digest.NewCache(10)
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0600))
+	require.NoError(t, c.Precompute(path))
+
+	require.NoError(t, os.WriteFile(path, []byte("CONTENT"), 0600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	_, ok := c.Lookup(path, info)
+	assert.False(t, ok)
+	require.NoError(t, c.Precompute(path))
+	sum, ok := c.Lookup(path, info)
+	assert.True(t, ok)
+	assert.Equal(t, sha256Of("CONTENT"), sum)
+}
+
+func TestCacheKeepsSumsOfMovedFiles(t *testing.T) {
+	c, err := digest.NewCache(10)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(path, []byte("content"), 0600))
+	require.NoError(t, c.Precompute(path))
+
+	moved := filepath.Join(dir, "moved.txt")
+	require.NoError(t, os.Rename(path, moved))
+	info, err := os.Stat(moved)
+	require.NoError(t, err)
+	sum, ok := c.Lookup(moved, info)
+	assert.True(t, ok)
+	assert.Equal(t, sha256Of("content"), sum)
+}
+
+func TestCachePrecomputeSkipsSymlinks(t *testing.T) {
+	c, err := digest.NewCache(10)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	target := filepath.Join(dir, "file.txt")
+	require.NoError(t, os.WriteFile(target, []byte("content"), 0600))
+	link := filepath.Join(dir, "link")
+	require.NoError(t, os.Symlink(target, link))
+	require.NoError(t, c.Precompute(link))
+
+	info, err := os.Stat(target)
+	require.NoError(t, err)
+	_, ok := c.Lookup(target, info)
+	assert.False(t, ok)
+}
+
+func TestETag(t *testing.T) {
+	assert.Equal(t, `"abc"`, digest.ETag("abc"))
+}
@@ -0,0 +1,10 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package digest
+
+import "os"
+
+func keyOf(path string, info os.FileInfo) key {
+	return key{path: path, modTime: info.ModTime().UnixNano(), size: info.Size()}
+}
@@ -0,0 +1,19 @@
+//go:build !windows && !plan9
+// +build !windows,!plan9
+
+package digest
+
+import (
+	"os"
+	"syscall"
+)
+
+func keyOf(path string, info os.FileInfo) key {
+	k := key{modTime: info.ModTime().UnixNano(), size: info.Size()}
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		k.dev, k.ino = uint64(st.Dev), st.Ino //nolint: unconvert
+		return k
+	}
+	k.path = path
+	return k
+}
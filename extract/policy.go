@@ -0,0 +1,118 @@
+package extract
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Action is what the extraction does with an entry that
+// falls under one of the cases of the policy.
+type Action string
+
+const (
+	Allow    Action = "allow"
+	Reject   Action = "reject"
+	Skip     Action = "skip"
+	Sanitize Action = "sanitize"
+)
+
+// Cases handled by the extraction policy.
+const (
+	CaseSymlink      = "symlink"
+	CaseHardlink     = "hardlink"
+	CaseDevice       = "device"
+	CaseSpecialBits  = "special_bits"
+	CaseAbsolutePath = "absolute_path"
+)
+
+// Policy decides what to do with the risky entries of an archive:
+//
+//   - Symlinks resolving outside the destination. Sanitizing them
+//     rewrites their targets as if the destination was the root.
+//   - Hard links. Sanitizing them extracts a copy of the linked file.
+//   - Character and block devices, and named pipes. Sanitizing
+//     them extracts empty regular files instead.
+//   - Files and directories with setuid, setgid or sticky bits.
+//     Sanitizing them clears those bits.
+//   - Absolute paths. Sanitizing them makes the paths relative to
+//     the destination.
+//
+// Rejecting any entry fails the whole extraction. Besides, the Umask
+// bits are cleared from the mode of all the files and directories,
+// and then the ForceMode bits are set on files.
+type Policy struct {
+	Symlinks      Action
+	Hardlinks     Action
+	Devices       Action
+	SpecialBits   Action
+	AbsolutePaths Action
+	Umask         os.FileMode
+	ForceMode     os.FileMode
+}
+
+func DefaultPolicy() *Policy {
+	return &Policy{
+		Symlinks:      Reject,
+		Hardlinks:     Sanitize,
+		Devices:       Skip,
+		SpecialBits:   Sanitize,
+		AbsolutePaths: Sanitize,
+		Umask:         0022, //nolint: gomnd
+	}
+}
+
+// Validate checks all the actions are supported by their cases. Devices
+// and absolute paths cannot be allowed, as the server cannot create the
+// former and the latter would be written outside the destination.
+func (p *Policy) Validate() error {
+	all := []Action{Allow, Reject, Skip, Sanitize}
+	checks := []struct {
+		c       string
+		action  Action
+		allowed []Action
+	}{
+		{CaseSymlink, p.Symlinks, all},
+		{CaseHardlink, p.Hardlinks, all},
+		{CaseDevice, p.Devices, all[1:]},
+		{CaseSpecialBits, p.SpecialBits, all},
+		{CaseAbsolutePath, p.AbsolutePaths, all[1:]},
+	}
+	for _, check := range checks {
+		if !containsAction(check.allowed, check.action) {
+			return fmt.Errorf("extract: action %q is not supported for %s entries", check.action, check.c)
+		}
+	}
+	return nil
+}
+
+func containsAction(actions []Action, action Action) bool {
+	for _, a := range actions {
+		if a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Decision records an action taken on an entry, other than allowing it.
+type Decision struct {
+	Path   string `json:"path"`
+	Case   string `json:"case"`
+	Action Action `json:"action"`
+}
+
+// RejectedError is returned when the policy rejects any entry.
+type RejectedError struct {
+	Decisions []Decision
+}
+
+func (e *RejectedError) Error() string {
+	var rejected []string
+	for _, d := range e.Decisions {
+		if d.Action == Reject {
+			rejected = append(rejected, fmt.Sprintf("%s (%s)", d.Path, d.Case))
+		}
+	}
+	return fmt.Sprintf("extract: rejected entries: %s", strings.Join(rejected, ", "))
+}
@@ -0,0 +1,348 @@
+package extract
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var errOutside = errors.New("entry path is outside the destination directory")
+
+// File describes a file written during an extraction.
+type File struct {
+	Path        string `json:"path"`
+	Bytes       int64  `json:"bytes"`
+	Overwritten bool   `json:"overwritten"`
+}
+
+// Report describes the outcome of an extraction. Paths are relative
+// to the destination directory, while decisions keep the entry names.
+type Report struct {
+	Bytes     int64      `json:"bytes"`
+	Files     []File     `json:"files"`
+	Decisions []Decision `json:"decisions,omitempty"`
+}
+
+// TARGZ extracts a tar.gz stream into the destination directory, applying
+// the policy, or the default one if nil, to every entry. Entries cannot
+// escape from the destination, neither by their names nor through
+// previously extracted symlinks. The report is returned even on failure,
+// so callers know what was written before the error.
+func TARGZ(r io.Reader, dst string, policy *Policy) (*Report, error) {
+	report := &Report{}
+	if policy == nil {
+		policy = DefaultPolicy()
+	}
+	if err := os.MkdirAll(dst, 0755); err != nil { //nolint: gomnd
+		return report, fmt.Errorf("extract: %w", err)
+	}
+	root, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		return report, fmt.Errorf("extract: %w", err)
+	}
+	x := &extractor{policy: policy, root: root, report: report}
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return report, fmt.Errorf("extract: %w", err)
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return report, fmt.Errorf("extract: %w", err)
+		}
+		if err := x.entry(tr, h); err != nil {
+			return report, fmt.Errorf("extract: %s: %w", h.Name, err)
+		}
+	}
+	for _, d := range report.Decisions {
+		if d.Action == Reject {
+			return report, &RejectedError{Decisions: report.Decisions}
+		}
+	}
+	return report, nil
+}
+
+type extractor struct {
+	policy *Policy
+	root   string
+	report *Report
+}
+
+func (x *extractor) entry(tr *tar.Reader, h *tar.Header) error {
+	name := h.Name
+	if strings.HasPrefix(filepath.ToSlash(name), "/") {
+		if x.decide(h.Name, CaseAbsolutePath, x.policy.AbsolutePaths) != Sanitize {
+			return nil
+		}
+		name = strings.TrimLeft(filepath.ToSlash(name), "/")
+	}
+	target, err := entryPath(x.root, name)
+	if err != nil {
+		return err
+	}
+	if target == x.root {
+		return nil
+	}
+	mode, ok := x.mode(h)
+	if !ok {
+		return nil
+	}
+	if err := x.prepare(target); err != nil {
+		return err
+	}
+	switch h.Typeflag {
+	case tar.TypeDir:
+		if err := os.MkdirAll(target, 0755); err != nil { //nolint: gomnd
+			return err
+		}
+		return os.Chmod(target, mode|0700) //nolint: gomnd
+	case tar.TypeReg, tar.TypeRegA: //nolint: staticcheck
+		return x.file(tr, name, target, mode|x.policy.ForceMode)
+	case tar.TypeSymlink:
+		return x.symlink(h, name, target)
+	case tar.TypeLink:
+		return x.hardlink(h, name, target)
+	case tar.TypeChar, tar.TypeBlock, tar.TypeFifo:
+		if x.decide(h.Name, CaseDevice, x.policy.Devices) != Sanitize {
+			return nil
+		}
+		return x.file(strings.NewReader(""), name, target, mode|x.policy.ForceMode)
+	default:
+		return nil
+	}
+}
+
+// decide records the action taken on an entry, if it is not
+// allowed, and returns it.
+func (x *extractor) decide(name, c string, action Action) Action {
+	if action != Allow {
+		x.report.Decisions = append(x.report.Decisions, Decision{Path: name, Case: c, Action: action})
+	}
+	return action
+}
+
+// mode returns the mode of the entry after applying the special
+// bits policy and the umask. It reports false if the entry must
+// not be extracted.
+func (x *extractor) mode(h *tar.Header) (os.FileMode, bool) {
+	const special = os.ModeSetuid | os.ModeSetgid | os.ModeSticky
+	mode := h.FileInfo().Mode() & (os.ModePerm | special)
+	if mode.Perm() == 0 {
+		mode |= 0644 //nolint: gomnd
+	}
+	if mode&special != 0 && (h.Typeflag == tar.TypeDir || h.Typeflag == tar.TypeReg || h.Typeflag == tar.TypeRegA) { //nolint: staticcheck
+		switch x.decide(h.Name, CaseSpecialBits, x.policy.SpecialBits) {
+		case Sanitize:
+			mode &^= special
+		case Allow:
+		default:
+			return 0, false
+		}
+	}
+	return mode &^ x.policy.Umask, true
+}
+
+// prepare creates the parent directories of the target, checking they
+// do not resolve outside the destination through symlinks. Symlinks at
+// the target are removed, so they are replaced instead of followed.
+func (x *extractor) prepare(target string) error {
+	existing := filepath.Dir(target)
+	for !exists(existing) {
+		existing = filepath.Dir(existing)
+	}
+	resolved, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return err
+	}
+	if !within(x.root, resolved) {
+		return errOutside
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil { //nolint: gomnd
+		return err
+	}
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(target)
+	}
+	return nil
+}
+
+func (x *extractor) file(r io.Reader, name, target string, mode os.FileMode) error {
+	overwritten := exists(target)
+	written, err := writeFile(r, target, mode)
+	x.report.Bytes += written
+	if err != nil {
+		return err
+	}
+	x.report.Files = append(x.report.Files, File{Path: name, Bytes: written, Overwritten: overwritten})
+	return nil
+}
+
+func (x *extractor) symlink(h *tar.Header, name, target string) error {
+	linkname := h.Linkname
+	dir, err := filepath.EvalSymlinks(filepath.Dir(target))
+	if err != nil {
+		return err
+	}
+	if _, ok := x.resolve(dir, linkname, 0); !ok {
+		switch x.decide(h.Name, CaseSymlink, x.policy.Symlinks) {
+		case Sanitize:
+			linkname = x.confine(dir, linkname)
+			if _, ok := x.resolve(dir, linkname, 0); !ok {
+				return errOutside
+			}
+		case Allow:
+		default:
+			return nil
+		}
+	}
+	overwritten := exists(target)
+	if overwritten {
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	if err := os.Symlink(linkname, target); err != nil {
+		return err
+	}
+	x.report.Files = append(x.report.Files, File{Path: name, Overwritten: overwritten})
+	return nil
+}
+
+// maxLinks is the maximum number of symlinks followed
+// when resolving a path, like most operating systems do.
+const maxLinks = 40
+
+// resolve returns the path a symlink in dir would point to, following the
+// symlinks already extracted. It reports false if the link is absolute or
+// the path leaves the destination at any step.
+func (x *extractor) resolve(dir, linkname string, depth int) (string, bool) {
+	if depth > maxLinks || filepath.IsAbs(linkname) {
+		return "", false
+	}
+	current := dir
+	for _, c := range strings.Split(filepath.ToSlash(linkname), "/") {
+		switch c {
+		case "", ".":
+			continue
+		case "..":
+			current = filepath.Dir(current)
+		default:
+			current = filepath.Join(current, c)
+		}
+		if !within(x.root, current) {
+			return "", false
+		}
+		info, err := os.Lstat(current)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 {
+			continue
+		}
+		link, err := os.Readlink(current)
+		if err != nil {
+			return "", false
+		}
+		var ok bool
+		if current, ok = x.resolve(filepath.Dir(current), link, depth+1); !ok {
+			return "", false
+		}
+	}
+	return current, true
+}
+
+// confine rewrites the target of a symlink in dir as if the destination
+// was the root of the file system, so it cannot point outside of it.
+func (x *extractor) confine(dir, linkname string) string {
+	rel := linkname
+	if !filepath.IsAbs(linkname) {
+		rel, _ = filepath.Rel(x.root, filepath.Join(dir, linkname))
+	}
+	confined := filepath.Join(x.root, filepath.Clean(string(filepath.Separator)+rel))
+	result, _ := filepath.Rel(dir, confined)
+	return result
+}
+
+// hardlink links the target to a previously extracted entry. Sanitized
+// hard links are extracted as copies, with the mode of the linked file.
+func (x *extractor) hardlink(h *tar.Header, name, target string) error {
+	action := x.decide(h.Name, CaseHardlink, x.policy.Hardlinks)
+	if action != Allow && action != Sanitize {
+		return nil
+	}
+	source, err := entryPath(x.root, strings.TrimLeft(filepath.ToSlash(h.Linkname), "/"))
+	if err != nil {
+		return err
+	}
+	if action == Sanitize {
+		info, err := os.Lstat(source)
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return errors.New("hard link source is not a regular file")
+		}
+		file, err := os.Open(source)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		return x.file(file, name, target, info.Mode()&(os.ModePerm|os.ModeSetuid|os.ModeSetgid|os.ModeSticky))
+	}
+	overwritten := exists(target)
+	if overwritten {
+		if err := os.Remove(target); err != nil {
+			return err
+		}
+	}
+	if err := os.Link(source, target); err != nil {
+		return err
+	}
+	x.report.Files = append(x.report.Files, File{Path: name, Overwritten: overwritten})
+	return nil
+}
+
+// entryPath returns the path where an entry must be extracted,
+// failing if it would end outside the destination directory.
+func entryPath(dst, name string) (string, error) {
+	target := filepath.Join(dst, name) //nolint: gosec
+	if !within(dst, target) {
+		return "", errOutside
+	}
+	return target, nil
+}
+
+func within(root, path string) bool {
+	root = filepath.Clean(root)
+	return path == root || strings.HasPrefix(path, root+string(filepath.Separator))
+}
+
+// writeFile writes the file with the exact mode, regardless
+// of the umask of the process.
+func writeFile(r io.Reader, path string, mode os.FileMode) (int64, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+	if err != nil {
+		return 0, err
+	}
+	written, err := io.Copy(file, r)
+	if err != nil {
+		_ = file.Close()
+		return written, err
+	}
+	if err := file.Close(); err != nil {
+		return written, err
+	}
+	return written, os.Chmod(path, mode)
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
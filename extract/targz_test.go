@@ -0,0 +1,195 @@
+// +build unit
+
+package extract_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/extract"
+)
+
+type entry struct {
+	header  tar.Header
+	content string
+}
+
+func targz(t *testing.T, entries ...entry) *bytes.Buffer {
+	buf := bytes.NewBuffer(nil)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, e := range entries {
+		h := e.header
+		if h.Typeflag == tar.TypeReg {
+			h.Size = int64(len(e.content))
+		}
+		if h.Mode == 0 {
+			h.Mode = 0644
+		}
+		require.NoError(t, tw.WriteHeader(&h))
+		_, err := tw.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf
+}
+
+func TestTARGZReport(t *testing.T) {
+	dst := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dst, "a.txt"), []byte("old"), 0600))
+
+	report, err := extract.TARGZ(targz(t,
+		entry{header: tar.Header{Name: "a.txt", Typeflag: tar.TypeReg}, content: "hello"},
+		entry{header: tar.Header{Name: "dir/", Typeflag: tar.TypeDir, Mode: 0755}},
+		entry{header: tar.Header{Name: "dir/b.txt", Typeflag: tar.TypeReg}, content: "world!"},
+	), dst, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(11), report.Bytes)
+	assert.Equal(t, []extract.File{
+		{Path: "a.txt", Bytes: 5, Overwritten: true},
+		{Path: "dir/b.txt", Bytes: 6},
+	}, report.Files)
+
+	data, err := os.ReadFile(filepath.Join(dst, "dir", "b.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "world!", string(data))
+}
+
+func TestTARGZCannotEscapeDestination(t *testing.T) {
+	dst := t.TempDir()
+	report, err := extract.TARGZ(targz(t,
+		entry{header: tar.Header{Name: "ok.txt", Typeflag: tar.TypeReg}, content: "ok"},
+		entry{header: tar.Header{Name: "../evil.txt", Typeflag: tar.TypeReg}, content: "evil"},
+	), filepath.Join(dst, "deploy"), nil)
+	assert.Error(t, err)
+	assert.Len(t, report.Files, 1)
+	assert.NoFileExists(t, filepath.Join(dst, "evil.txt"))
+}
+
+func TestTARGZPolicy(t *testing.T) {
+	archive := func() *bytes.Buffer {
+		return targz(t,
+			entry{header: tar.Header{Name: "/abs.txt", Typeflag: tar.TypeReg}, content: "abs"},
+			entry{header: tar.Header{Name: "suid", Typeflag: tar.TypeReg, Mode: 04755}, content: "suid"},
+			entry{header: tar.Header{Name: "link", Typeflag: tar.TypeLink, Linkname: "suid"}},
+			entry{header: tar.Header{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}},
+			entry{header: tar.Header{Name: "up", Typeflag: tar.TypeSymlink, Linkname: "../../outside"}},
+			entry{header: tar.Header{Name: "inside", Typeflag: tar.TypeSymlink, Linkname: "suid"}},
+			entry{header: tar.Header{Name: "null", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3}},
+			entry{header: tar.Header{Name: "group", Typeflag: tar.TypeReg, Mode: 0777}, content: "group"},
+		)
+	}
+
+	t.Run("default", func(t *testing.T) {
+		dst := t.TempDir()
+		report, err := extract.TARGZ(archive(), dst, nil)
+		var rejected *extract.RejectedError
+		require.True(t, errors.As(err, &rejected))
+		assert.Equal(t, []extract.Decision{
+			{Path: "/abs.txt", Case: extract.CaseAbsolutePath, Action: extract.Sanitize},
+			{Path: "suid", Case: extract.CaseSpecialBits, Action: extract.Sanitize},
+			{Path: "link", Case: extract.CaseHardlink, Action: extract.Sanitize},
+			{Path: "passwd", Case: extract.CaseSymlink, Action: extract.Reject},
+			{Path: "up", Case: extract.CaseSymlink, Action: extract.Reject},
+			{Path: "null", Case: extract.CaseDevice, Action: extract.Skip},
+		}, report.Decisions)
+		assert.Equal(t, rejected.Decisions, report.Decisions)
+		assert.Contains(t, err.Error(), "passwd (symlink), up (symlink)")
+
+		assert.FileExists(t, filepath.Join(dst, "abs.txt"))
+		assertMode(t, filepath.Join(dst, "suid"), 0755)
+		assertMode(t, filepath.Join(dst, "group"), 0755)
+		assertMode(t, filepath.Join(dst, "link"), 0755)
+		link, err := os.Lstat(filepath.Join(dst, "link"))
+		require.NoError(t, err)
+		suid, err := os.Lstat(filepath.Join(dst, "suid"))
+		require.NoError(t, err)
+		assert.False(t, os.SameFile(link, suid), "hard link must be extracted as a copy")
+		target, err := os.Readlink(filepath.Join(dst, "inside"))
+		require.NoError(t, err)
+		assert.Equal(t, "suid", target)
+		assert.NoFileExists(t, filepath.Join(dst, "passwd"))
+		assert.NoFileExists(t, filepath.Join(dst, "null"))
+	})
+
+	t.Run("sanitize", func(t *testing.T) {
+		dst := t.TempDir()
+		policy := &extract.Policy{
+			Symlinks:      extract.Sanitize,
+			Hardlinks:     extract.Allow,
+			Devices:       extract.Sanitize,
+			SpecialBits:   extract.Skip,
+			AbsolutePaths: extract.Skip,
+			Umask:         0077,
+			ForceMode:     0600,
+		}
+		require.NoError(t, policy.Validate())
+		_, err := extract.TARGZ(archive(), dst, policy)
+		require.Error(t, err, "the hard link to the skipped file must fail")
+
+		dst = t.TempDir()
+		policy.SpecialBits = extract.Allow
+		report, err := extract.TARGZ(archive(), dst, policy)
+		require.NoError(t, err)
+		assert.Len(t, report.Decisions, 4)
+
+		assert.NoFileExists(t, filepath.Join(dst, "abs.txt"))
+		passwd, err := os.Readlink(filepath.Join(dst, "passwd"))
+		require.NoError(t, err)
+		assert.Equal(t, "etc/passwd", passwd)
+		up, err := os.Readlink(filepath.Join(dst, "up"))
+		require.NoError(t, err)
+		assert.Equal(t, "outside", up)
+		assertMode(t, filepath.Join(dst, "null"), 0600)
+		assertMode(t, filepath.Join(dst, "group"), 0700)
+		info, err := os.Lstat(filepath.Join(dst, "suid"))
+		require.NoError(t, err)
+		assert.NotZero(t, info.Mode()&os.ModeSetuid)
+	})
+}
+
+func TestTARGZCannotWriteThroughSymlinks(t *testing.T) {
+	outside := t.TempDir()
+	dst := t.TempDir()
+	policy := extract.DefaultPolicy()
+	policy.Symlinks = extract.Allow
+	_, err := extract.TARGZ(targz(t,
+		entry{header: tar.Header{Name: "evil", Typeflag: tar.TypeSymlink, Linkname: outside}},
+		entry{header: tar.Header{Name: "evil/file.txt", Typeflag: tar.TypeReg}, content: "evil"},
+	), dst, policy)
+	assert.Error(t, err)
+	assert.NoFileExists(t, filepath.Join(outside, "file.txt"))
+
+	_, err = extract.TARGZ(targz(t,
+		entry{header: tar.Header{Name: "sub/", Typeflag: tar.TypeDir, Mode: 0755}},
+		entry{header: tar.Header{Name: "sub/self", Typeflag: tar.TypeSymlink, Linkname: "."}},
+		entry{header: tar.Header{Name: "escape", Typeflag: tar.TypeSymlink, Linkname: "sub/self/../.."}},
+	), t.TempDir(), nil)
+	var rejected *extract.RejectedError
+	assert.True(t, errors.As(err, &rejected))
+}
+
+func TestPolicyValidate(t *testing.T) {
+	policy := extract.DefaultPolicy()
+	assert.NoError(t, policy.Validate())
+	policy.Devices = extract.Allow
+	assert.Error(t, policy.Validate())
+	policy = extract.DefaultPolicy()
+	policy.AbsolutePaths = "unknown"
+	assert.Error(t, policy.Validate())
+}
+
+func assertMode(t *testing.T, path string, mode os.FileMode) {
+	info, err := os.Lstat(path)
+	require.NoError(t, err)
+	assert.Equal(t, mode, info.Mode().Perm(), path)
+}
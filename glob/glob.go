@@ -0,0 +1,57 @@
+package glob
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Compile translates a slash separated path glob into an anchored regular
+// expression. A "*" matches any sequence of characters inside a single path
+// segment, a "**" matches across segments and a "?" matches exactly one
+// non separator character. Every "*" and "**" becomes a capture group, so
+// callers can reuse the matched parts of the path.
+func Compile(pattern string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch c := runes[i]; c {
+		case '*':
+			if i+1 < len(runes) && runes[i+1] == '*' {
+				b.WriteString("(.*)")
+				i++
+				continue
+			}
+			b.WriteString("([^/]*)")
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteString("$")
+	r, err := regexp.Compile(b.String())
+	if err != nil {
+		return nil, fmt.Errorf("glob: %w", err)
+	}
+	return r, nil
+}
+
+// MustCompile is like Compile but panics if the pattern cannot be compiled.
+func MustCompile(pattern string) *regexp.Regexp {
+	r, err := Compile(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// Match reports whether name matches the glob pattern.
+func Match(pattern, name string) bool {
+	r, err := Compile(pattern)
+	if err != nil {
+		return false
+	}
+	return r.MatchString(name)
+}
@@ -0,0 +1,37 @@
+// +build unit
+
+package glob_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.eloylp.dev/go-serve/glob"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"/releases/*", "/releases/v1.0.0", true},
+		{"/releases/*", "/releases/v1.0.0/app.tar.gz", false},
+		{"/releases/**", "/releases/v1.0.0/app.tar.gz", true},
+		{"/releases/*.tar.gz", "/releases/app.tar.gz", true},
+		{"/releases/*.tar.gz", "/releases/app.zip", false},
+		{"/v?/index.html", "/v1/index.html", true},
+		{"/v?/index.html", "/v10/index.html", false},
+		{"**/.git/**", "/site/.git/config", true},
+		{"/a+b/(c)", "/a+b/(c)", true},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.want, glob.Match(c.pattern, c.name), "pattern %q name %q", c.pattern, c.name)
+	}
+}
+
+func TestCompileCaptures(t *testing.T) {
+	r := glob.MustCompile("/docs/*/**")
+	assert.Equal(t, []string{"/docs/v1/guide/index.html", "v1", "guide/index.html"}, r.FindStringSubmatch("/docs/v1/guide/index.html"))
+}
@@ -3,10 +3,12 @@ module go.eloylp.dev/go-serve
 go 1.16
 
 require (
+	github.com/andybalholm/brotli v1.0.3
 	github.com/hashicorp/go-immutable-radix v1.3.0
-	github.com/hashicorp/golang-lru v0.5.4 // indirect
+	github.com/hashicorp/golang-lru v0.5.4
 	github.com/julienschmidt/httprouter v1.3.0
 	github.com/kelseyhightower/envconfig v1.4.0
+	github.com/klauspost/compress v1.13.6
 	github.com/prometheus/client_golang v1.10.0
 	github.com/prometheus/common v0.25.0 // indirect
 	github.com/sirupsen/logrus v1.8.1
@@ -0,0 +1,106 @@
+package headers
+
+import (
+	"fmt"
+	"net/http"
+	stdpath "path"
+	"regexp"
+	"strings"
+
+	"go.eloylp.dev/go-serve/glob"
+)
+
+// Actions that rules can apply to a header.
+const (
+	Set    = "set"
+	Append = "append"
+	Remove = "remove"
+)
+
+// Rule applies the action to the header of the responses to the
+// requests whose paths match the pattern. Patterns are globs, unless
+// prefixed with "~", which makes them regular expressions. Globs without
+// slashes match the name of the requested file, like "index.html".
+type Rule struct {
+	Pattern string
+	Action  string
+	Name    string
+	Value   string
+}
+
+// Rules applies header rules to responses, in order.
+type Rules struct {
+	rules []compiled
+}
+
+type compiled struct {
+	Rule
+	re       *regexp.Regexp
+	nameOnly bool
+}
+
+func New(rules []Rule) (*Rules, error) {
+	result := &Rules{}
+	for _, r := range rules {
+		c := compiled{Rule: r, nameOnly: !strings.HasPrefix(r.Pattern, "~") && !strings.Contains(r.Pattern, "/")}
+		var err error
+		if strings.HasPrefix(r.Pattern, "~") {
+			c.re, err = regexp.Compile(strings.TrimPrefix(r.Pattern, "~"))
+		} else {
+			c.re, err = glob.Compile(r.Pattern)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("headers: invalid pattern %q: %w", r.Pattern, err)
+		}
+		switch r.Action {
+		case Set, Append, Remove:
+		default:
+			return nil, fmt.Errorf("headers: invalid action %q", r.Action)
+		}
+		if !validName(r.Name) {
+			return nil, fmt.Errorf("headers: invalid header name %q", r.Name)
+		}
+		result.rules = append(result.rules, c)
+	}
+	return result, nil
+}
+
+// Apply applies the rules matching the request path to the headers.
+func (r *Rules) Apply(path string, h http.Header) {
+	for _, c := range r.rules {
+		subject := path
+		if c.nameOnly {
+			subject = stdpath.Base(path)
+		}
+		if !c.re.MatchString(subject) {
+			continue
+		}
+		switch c.Action {
+		case Set:
+			h.Set(c.Name, c.Value)
+		case Append:
+			h.Add(c.Name, c.Value)
+		case Remove:
+			h.Del(c.Name)
+		}
+	}
+}
+
+// validName reports whether the header name is a token, made only
+// of the characters allowed by RFC 7230, so it cannot contain spaces,
+// separators or control characters.
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,54 @@
+// +build unit
+
+package headers_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/headers"
+)
+
+func TestRules(t *testing.T) {
+	rules, err := headers.New([]headers.Rule{
+		{Pattern: "/**", Action: headers.Set, Name: "Cache-Control", Value: "max-age=60"},
+		{Pattern: "/static/assets/*", Action: headers.Set, Name: "Cache-Control", Value: "immutable, max-age=31536000"},
+		{Pattern: "index.html", Action: headers.Set, Name: "Cache-Control", Value: "no-cache"},
+		{Pattern: `~\.js$`, Action: headers.Append, Name: "Vary", Value: "Origin"},
+		{Pattern: "/**", Action: headers.Remove, Name: "X-Powered-By"},
+	})
+	require.NoError(t, err)
+	cases := []struct {
+		path         string
+		cacheControl string
+		vary         []string
+	}{
+		{"/static/notes.txt", "max-age=60", []string{"Accept-Encoding"}},
+		{"/static/assets/app.js", "immutable, max-age=31536000", []string{"Accept-Encoding", "Origin"}},
+		{"/static/assets/js/app.js", "max-age=60", []string{"Accept-Encoding", "Origin"}},
+		{"/static/docs/index.html", "no-cache", []string{"Accept-Encoding"}},
+	}
+	for _, c := range cases {
+		h := http.Header{}
+		h.Set("Vary", "Accept-Encoding")
+		h.Set("X-Powered-By", "go")
+		rules.Apply(c.path, h)
+		assert.Equal(t, c.cacheControl, h.Get("Cache-Control"), c.path)
+		assert.Equal(t, c.vary, h.Values("Vary"), c.path)
+		assert.Empty(t, h.Get("X-Powered-By"), c.path)
+	}
+}
+
+func TestRulesInvalid(t *testing.T) {
+	_, err := headers.New([]headers.Rule{{Pattern: "~(", Action: headers.Set, Name: "X-Foo"}})
+	assert.Error(t, err)
+	_, err = headers.New([]headers.Rule{{Pattern: "/**", Action: "replace", Name: "X-Foo"}})
+	assert.Error(t, err)
+	for _, name := range []string{"", "Cache Control", "X-Foo:", "X-Föo", "X-Foo\r\n"} {
+		_, err = headers.New([]headers.Rule{{Pattern: "/**", Action: headers.Set, Name: name, Value: "bar"}})
+		assert.Error(t, err, name)
+	}
+}
@@ -0,0 +1,85 @@
+package hidden
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.eloylp.dev/go-serve/glob"
+)
+
+// DefaultPatterns hide dotfiles, like .env or .git, except
+// .well-known, and CVS directories.
+var DefaultPatterns = []string{".*", "!.well-known", "CVS"}
+
+// Matcher decides which paths are hidden, with gitignore like patterns.
+// Patterns without slashes match the name of a file at any depth. Other
+// patterns match the whole path, relative to the root. Patterns prefixed
+// with "!" make matching paths visible again. The last matching pattern
+// wins, and paths inside a hidden directory are always hidden.
+type Matcher struct {
+	patterns []pattern
+}
+
+type pattern struct {
+	re       *regexp.Regexp
+	negate   bool
+	fullPath bool
+}
+
+func New(patterns []string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		negate := strings.HasPrefix(p, "!")
+		p = strings.TrimPrefix(p, "!")
+		fullPath := strings.Contains(p, "/")
+		re, err := glob.Compile(strings.Trim(p, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("hidden: %w", err)
+		}
+		m.patterns = append(m.patterns, pattern{re: re, negate: negate, fullPath: fullPath})
+	}
+	return m, nil
+}
+
+// Hidden reports whether the slash separated path, relative
+// to the root, or any of its parent directories is hidden.
+// The "." and ".." segments are never matched, as they do
+// not name files.
+func (m *Matcher) Hidden(path string) bool {
+	if m == nil || len(m.patterns) == 0 {
+		return false
+	}
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return false
+	}
+	segments := strings.Split(path, "/")
+	for i := range segments {
+		if segments[i] == "." || segments[i] == ".." {
+			continue
+		}
+		if m.matches(strings.Join(segments[:i+1], "/"), segments[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Matcher) matches(path, name string) bool {
+	hidden := false
+	for _, p := range m.patterns {
+		subject := name
+		if p.fullPath {
+			subject = path
+		}
+		if p.re.MatchString(subject) {
+			hidden = !p.negate
+		}
+	}
+	return hidden
+}
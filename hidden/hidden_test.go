@@ -0,0 +1,44 @@
+// +build unit
+
+package hidden_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/hidden"
+)
+
+func TestMatcher(t *testing.T) {
+	m, err := hidden.New(append(hidden.DefaultPatterns, "/private/**", "*.bak", "!keep.bak"))
+	require.NoError(t, err)
+	cases := map[string]bool{
+		"/":                              false,
+		"/index.html":                    false,
+		"/.env":                          true,
+		"/.git":                          true,
+		"/.git/config":                   true,
+		"/app/.git/HEAD":                 true,
+		"/app/CVS/Entries":               true,
+		"/.well-known/acme-challenge/ab": false,
+		"/private":                       false,
+		"/private/notes.txt":             true,
+		"/public/private/notes.txt":      false,
+		"/notes.bak":                     true,
+		"/keep.bak":                      false,
+		".":                              false,
+		"./index.html":                   false,
+		"/notes/../index.html":           false,
+		"/notes/./.env":                  true,
+	}
+	for path, expected := range cases {
+		assert.Equal(t, expected, m.Hidden(path), path)
+	}
+}
+
+func TestNilMatcher(t *testing.T) {
+	var m *hidden.Matcher
+	assert.False(t, m.Hidden("/.env"))
+}
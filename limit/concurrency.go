@@ -0,0 +1,29 @@
+package limit
+
+// Concurrency caps the number of operations that can run at
+// the same time.
+type Concurrency struct {
+	slots chan struct{}
+}
+
+func NewConcurrency(max int) *Concurrency {
+	return &Concurrency{
+		slots: make(chan struct{}, max),
+	}
+}
+
+// TryAcquire takes a slot without blocking. It reports false if
+// all slots are in use. Acquired slots must be returned by calling
+// Release.
+func (c *Concurrency) TryAcquire() bool {
+	select {
+	case c.slots <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Concurrency) Release() {
+	<-c.slots
+}
@@ -0,0 +1,81 @@
+package limit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const sweepInterval = time.Minute
+
+// Rate is a token bucket rate limiter, keeping an independent bucket
+// per key. Buckets are refilled at the configured rate per second,
+// up to burst tokens. Full buckets are periodically forgotten, as they
+// are equivalent to new ones.
+type Rate struct {
+	rate      float64
+	burst     float64
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRate creates a rate limiter allowing rate requests per second per key.
+// If burst is not positive, it defaults to the rate, with a minimum of one.
+func NewRate(rate float64, burst int) *Rate {
+	b := float64(burst)
+	if b <= 0 {
+		b = math.Max(1, math.Ceil(rate))
+	}
+	return &Rate{
+		rate:    rate,
+		burst:   b,
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow consumes a token from the bucket of the key. If there are no
+// tokens left, it returns false and the time until the next one is
+// available.
+func (r *Rate) Allow(key string) (bool, time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := r.now()
+	r.sweep(now)
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &bucket{tokens: r.burst, last: now}
+		r.buckets[key] = b
+	}
+	r.refill(b, now)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / r.rate * float64(time.Second))
+}
+
+func (r *Rate) refill(b *bucket, now time.Time) {
+	b.tokens = math.Min(r.burst, b.tokens+now.Sub(b.last).Seconds()*r.rate)
+	b.last = now
+}
+
+func (r *Rate) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < sweepInterval {
+		return
+	}
+	r.lastSweep = now
+	for k, b := range r.buckets {
+		r.refill(b, now)
+		if b.tokens >= r.burst {
+			delete(r.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// +build unit
+
+package limit //nolint:testpackage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateAllow(t *testing.T) {
+	now := time.Now()
+	r := NewRate(2, 3)
+	r.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := r.Allow("10.0.0.1")
+		assert.True(t, ok, "request %d should be allowed by burst", i)
+	}
+	ok, wait := r.Allow("10.0.0.1")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// Keys are limited independently.
+	ok, _ = r.Allow("10.0.0.2")
+	assert.True(t, ok)
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = r.Allow("10.0.0.1")
+	assert.True(t, ok)
+}
+
+func TestRateForgetsFullBuckets(t *testing.T) {
+	now := time.Now()
+	r := NewRate(1, 0)
+	r.now = func() time.Time { return now }
+
+	r.Allow("10.0.0.1")
+	assert.Len(t, r.buckets, 1)
+
+	now = now.Add(2 * sweepInterval)
+	r.Allow("10.0.0.2")
+	assert.Len(t, r.buckets, 1)
+	assert.Contains(t, r.buckets, "10.0.0.2")
+}
+
+func TestConcurrency(t *testing.T) {
+	c := NewConcurrency(1)
+	assert.True(t, c.TryAcquire())
+	assert.False(t, c.TryAcquire())
+	c.Release()
+	assert.True(t, c.TryAcquire())
+}
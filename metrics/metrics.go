@@ -1,12 +1,52 @@
 package metrics
 
 import (
+	"context"
+
 	"github.com/prometheus/client_golang/prometheus"
 
 	"go.eloylp.dev/go-serve/config"
 )
 
-var UploadSize *prometheus.HistogramVec
+var (
+	UploadSize          *prometheus.HistogramVec
+	RateLimitRejections *prometheus.CounterVec
+	AuthFailures        *prometheus.CounterVec
+	UploadScans         *prometheus.CounterVec
+	CompressionCache    *prometheus.CounterVec
+	RedirectHits        *prometheus.CounterVec
+)
+
+// hostLabel tells whether the metrics are labeled by host,
+// which they are when there are virtual hosts.
+var hostLabel bool
+
+type contextKey int
+
+const hostContextKey contextKey = iota
+
+// WithHost returns a copy of the context with the name of the
+// host whose metrics are observed with it.
+func WithHost(ctx context.Context, host string) context.Context {
+	return context.WithValue(ctx, hostContextKey, host)
+}
+
+// Labels returns the label values, followed by the host of
+// the context when the metrics are labeled by host.
+func Labels(ctx context.Context, values ...string) []string {
+	if !hostLabel {
+		return values
+	}
+	host, _ := ctx.Value(hostContextKey).(string)
+	return append(values, host)
+}
+
+func labelNames(names ...string) []string {
+	if !hostLabel {
+		return names
+	}
+	return append(names, "host")
+}
 
 func uploadSize(buckets []float64) *prometheus.HistogramVec {
 	return prometheus.NewHistogramVec(prometheus.HistogramOpts{
@@ -15,10 +55,61 @@ func uploadSize(buckets []float64) *prometheus.HistogramVec {
 		Name:      "size",
 		Help:      "Histogram to represent the successful uploads to the server",
 		Buckets:   buckets,
-	}, []string{})
+	}, labelNames())
+}
+
+func rateLimitRejections() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "http",
+		Subsystem: "rate_limit",
+		Name:      "rejections_total",
+		Help:      "Counter of the requests rejected by rate or concurrency limits, by reason",
+	}, labelNames("reason"))
+}
+
+func authFailures() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "http",
+		Subsystem: "auth",
+		Name:      "failures_total",
+		Help:      "Counter of the failed authentications, by mechanism",
+	}, labelNames("mechanism"))
+}
+
+func uploadScans() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "http",
+		Subsystem: "upload",
+		Name:      "scans_total",
+		Help:      "Counter of the malware scans of uploaded files, by result",
+	}, labelNames("result"))
+}
+
+func compressionCache() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "http",
+		Subsystem: "compression",
+		Name:      "cache_requests_total",
+		Help:      "Counter of the lookups in the compressed responses cache, by result",
+	}, labelNames("result"))
+}
+
+func redirectHits() *prometheus.CounterVec {
+	return prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "http",
+		Subsystem: "redirect",
+		Name:      "rule_hits_total",
+		Help:      "Counter of the requests redirected or rewritten, by rule and status",
+	}, labelNames("rule", "status"))
 }
 
 func Initialize(cfg *config.Settings) {
+	hostLabel = len(cfg.VirtualHosts) > 0
 	UploadSize = uploadSize(cfg.MetricsSizeBuckets)
-	prometheus.MustRegister(UploadSize)
+	RateLimitRejections = rateLimitRejections()
+	AuthFailures = authFailures()
+	UploadScans = uploadScans()
+	CompressionCache = compressionCache()
+	RedirectHits = redirectHits()
+	prometheus.MustRegister(UploadSize, RateLimitRejections, AuthFailures, UploadScans, CompressionCache, RedirectHits)
 }
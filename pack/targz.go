@@ -0,0 +1,95 @@
+package pack
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	stdpath "path"
+	"path/filepath"
+	"strings"
+
+	"go.eloylp.dev/go-serve/storage"
+)
+
+// Filter decides whether the file at name, a path of the storage,
+// is added to the archive. Excluded directories are not walked.
+type Filter func(name string, info os.FileInfo) bool
+
+// TARGZ writes a tar.gz archive of the file at name of the storage to w,
+// returning the number of content bytes written. Entry names are relative
+// to the name, or the file name if it is a single file. Symlinks are
+// archived as such, never followed. A nil filter includes everything.
+func TARGZ(w io.Writer, s storage.Storage, name string, filter Filter) (int64, error) {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	var written int64
+	base := storage.Clean(name)
+	err := storage.Walk(s, base, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if filter != nil && !filter(name, info) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		n, err := add(tw, s, base, name, info)
+		written += n
+		return err
+	})
+	if err != nil {
+		return written, fmt.Errorf("pack: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return written, fmt.Errorf("pack: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return written, fmt.Errorf("pack: %w", err)
+	}
+	return written, nil
+}
+
+func add(tw *tar.Writer, s storage.Storage, base, name string, info os.FileInfo) (int64, error) {
+	var link string
+	if info.Mode()&os.ModeSymlink != 0 {
+		linker, ok := s.(storage.Linker)
+		if !ok {
+			return 0, fmt.Errorf("symlink %s not supported by the storage", name)
+		}
+		var err error
+		if link, err = linker.Readlink(name); err != nil {
+			return 0, err
+		}
+	}
+	h, err := tar.FileInfoHeader(info, link)
+	if err != nil {
+		return 0, err
+	}
+	h.Name = relative(base, name)
+	if name == base && !info.IsDir() {
+		h.Name = stdpath.Base(name)
+	}
+	if err := tw.WriteHeader(h); err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return 0, nil
+	}
+	file, err := s.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	return io.Copy(tw, file)
+}
+
+// relative returns the name relative to the base directory.
+func relative(base, name string) string {
+	if name == base {
+		return "."
+	}
+	return strings.TrimPrefix(name, strings.TrimSuffix(base, "/")+"/")
+}
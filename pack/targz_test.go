@@ -0,0 +1,73 @@
+// +build unit
+
+package pack_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/pack"
+	"go.eloylp.dev/go-serve/storage"
+)
+
+func entries(t *testing.T, r io.Reader) map[string]string {
+	gz, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	result := map[string]string{}
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			return result
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		result[h.Name] = string(data) + h.Linkname
+	}
+}
+
+func TestTARGZ(t *testing.T) {
+	root := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "dir", "skipped"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dir", "a.txt"), []byte("a"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dir", "skipped", "b.txt"), []byte("b"), 0600))
+	require.NoError(t, os.Symlink("a.txt", filepath.Join(root, "dir", "link")))
+
+	buf := bytes.NewBuffer(nil)
+	s := storage.NewLocal(root)
+	written, err := pack.TARGZ(buf, s, "/dir", func(name string, info os.FileInfo) bool {
+		return info.Name() != "skipped"
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), written)
+	assert.Equal(t, map[string]string{".": "", "a.txt": "a", "link": "a.txt"}, entries(t, buf))
+
+	buf.Reset()
+	_, err = pack.TARGZ(buf, s, "/dir/a.txt", nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a.txt": "a"}, entries(t, buf))
+}
+
+func TestTARGZFromMemory(t *testing.T) {
+	s := storage.NewMemory()
+	w, err := s.Create("/dir/sub/a.txt", 0644)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "a")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	buf := bytes.NewBuffer(nil)
+	written, err := pack.TARGZ(buf, s, "/", nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), written)
+	assert.Equal(t, map[string]string{".": "", "dir": "", "dir/sub": "", "dir/sub/a.txt": "a"}, entries(t, buf))
+}
@@ -0,0 +1,109 @@
+package redirect
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.eloylp.dev/go-serve/glob"
+)
+
+// Rewrite is the status of the rules that rewrite the
+// path internally instead of redirecting the client.
+const Rewrite = 200
+
+// Rule redirects or rewrites the paths matching the From pattern to the
+// To target. Patterns are globs, unless prefixed with "~", which makes them
+// regular expressions. Targets can refer to the wildcards of the globs and
+// the capture groups of the expressions with placeholders like $1 or ${1}.
+type Rule struct {
+	From   string
+	To     string
+	Status int
+}
+
+// Parse parses the rules, one per line, as in a _redirects file. Each
+// rule is a pattern followed by a target and an optional status, which
+// defaults to 301. Empty lines and lines starting with # are ignored.
+//
+//	/docs/v1/** /docs/v2/$1 308
+//	~^/builds/(\d+)/latest$ /builds/$1/current 200
+func Parse(text string) ([]Rule, error) {
+	var rules []Rule
+	for n, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.FieldsFunc(line, unicode.IsSpace)
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("redirect: invalid rule %q at line %d", line, n+1)
+		}
+		rule := Rule{From: fields[0], To: fields[1], Status: 301}
+		if len(fields) == 3 {
+			status, err := strconv.Atoi(fields[2])
+			if err != nil {
+				return nil, fmt.Errorf("redirect: invalid status %q at line %d", fields[2], n+1)
+			}
+			rule.Status = status
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Table holds compiled rules. The first matching rule wins.
+type Table struct {
+	rules []compiled
+}
+
+type compiled struct {
+	Rule
+	re *regexp.Regexp
+}
+
+func New(rules []Rule) (*Table, error) {
+	t := &Table{}
+	for _, r := range rules {
+		switch r.Status {
+		case 301, 302, 307, 308:
+		case Rewrite:
+			if !strings.HasPrefix(r.To, "/") {
+				return nil, fmt.Errorf("redirect: rewrite target %q must be a path", r.To)
+			}
+		default:
+			return nil, fmt.Errorf("redirect: unsupported status %d for %q", r.Status, r.From)
+		}
+		var re *regexp.Regexp
+		var err error
+		if strings.HasPrefix(r.From, "~") {
+			re, err = regexp.Compile(strings.TrimPrefix(r.From, "~"))
+		} else {
+			re, err = glob.Compile(r.From)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("redirect: invalid pattern %q: %w", r.From, err)
+		}
+		t.rules = append(t.rules, compiled{Rule: r, re: re})
+	}
+	return t, nil
+}
+
+// Match returns the first rule matching the path, along
+// with its target, placeholders already expanded.
+func (t *Table) Match(path string) (Rule, string, bool) {
+	if t == nil {
+		return Rule{}, "", false
+	}
+	for _, c := range t.rules {
+		match := c.re.FindStringSubmatchIndex(path)
+		if match == nil {
+			continue
+		}
+		target := c.re.ExpandString(nil, c.To, path, match)
+		return c.Rule, string(target), true
+	}
+	return Rule{}, "", false
+}
@@ -0,0 +1,71 @@
+// +build unit
+
+package redirect_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/redirect"
+)
+
+func TestParse(t *testing.T) {
+	rules, err := redirect.Parse(`
+		# Moved docs.
+		/docs/v1/**   /docs/v2/$1   308
+		/old.txt https://example.com/new.txt
+		~^/builds/(\d+)/latest$ /builds/${1}/current 200
+	`)
+	require.NoError(t, err)
+	assert.Equal(t, []redirect.Rule{
+		{From: "/docs/v1/**", To: "/docs/v2/$1", Status: 308},
+		{From: "/old.txt", To: "https://example.com/new.txt", Status: 301},
+		{From: `~^/builds/(\d+)/latest$`, To: "/builds/${1}/current", Status: 200},
+	}, rules)
+
+	_, err = redirect.Parse("/old.txt")
+	assert.Error(t, err)
+	_, err = redirect.Parse("/old.txt /new.txt permanent")
+	assert.Error(t, err)
+	_, err = redirect.Parse("/old.txt /new.txt 301 force")
+	assert.Error(t, err)
+}
+
+func TestTable(t *testing.T) {
+	table, err := redirect.New([]redirect.Rule{
+		{From: "/docs/v1/**", To: "/docs/v2/$1", Status: 308},
+		{From: "/releases/*/*.zip", To: "/builds/${1}/${2}.tar.gz", Status: 302},
+		{From: `~^/builds/(?P<version>\d+)/latest$`, To: "/builds/${version}/current", Status: redirect.Rewrite},
+	})
+	require.NoError(t, err)
+	cases := []struct {
+		path   string
+		target string
+		status int
+	}{
+		{"/docs/v1/guide/intro.html", "/docs/v2/guide/intro.html", 308},
+		{"/releases/v1/app.zip", "/builds/v1/app.tar.gz", 302},
+		{"/builds/12/latest", "/builds/12/current", 200},
+	}
+	for _, c := range cases {
+		rule, target, ok := table.Match(c.path)
+		require.True(t, ok, c.path)
+		assert.Equal(t, c.target, target, c.path)
+		assert.Equal(t, c.status, rule.Status, c.path)
+	}
+	_, _, ok := table.Match("/releases/v1/sub/app.zip")
+	assert.False(t, ok)
+	_, _, ok = table.Match("/docs/v2/guide.html")
+	assert.False(t, ok)
+}
+
+func TestTableInvalid(t *testing.T) {
+	_, err := redirect.New([]redirect.Rule{{From: "/a", To: "/b", Status: 303}})
+	assert.Error(t, err)
+	_, err = redirect.New([]redirect.Rule{{From: "/a", To: "https://example.com", Status: redirect.Rewrite}})
+	assert.Error(t, err)
+	_, err = redirect.New([]redirect.Rule{{From: "~(", To: "/b", Status: 301}})
+	assert.Error(t, err)
+}
@@ -0,0 +1,96 @@
+// +build unit
+
+package scan_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/scan"
+)
+
+// fakeClamd answers INSTREAM commands, flagging as infected any
+// stream containing the word "virus".
+func fakeClamd(t *testing.T, network, address string) string {
+	l, err := net.Listen(network, address)
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveClamd(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveClamd(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil || cmd != "zINSTREAM\x00" {
+		_, _ = conn.Write([]byte("UNKNOWN COMMAND\x00"))
+		return
+	}
+	var data bytes.Buffer
+	for {
+		var size uint32
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return
+		}
+		if size == 0 {
+			break
+		}
+		if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+			return
+		}
+	}
+	if strings.Contains(data.String(), "virus") {
+		_, _ = conn.Write([]byte("stream: Test.Virus FOUND\x00"))
+		return
+	}
+	_, _ = conn.Write([]byte("stream: OK\x00"))
+}
+
+func TestClamd(t *testing.T) {
+	address := fakeClamd(t, "tcp", "127.0.0.1:0")
+	clamd := scan.NewClamd("tcp://" + address)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	result, err := clamd.Scan(ctx, strings.NewReader("a clean file"))
+	require.NoError(t, err)
+	assert.False(t, result.Infected)
+
+	big := strings.Repeat("a", 200*1024) + "virus"
+	result, err = clamd.Scan(ctx, strings.NewReader(big))
+	require.NoError(t, err)
+	assert.True(t, result.Infected)
+	assert.Equal(t, "Test.Virus", result.Signature)
+}
+
+func TestClamdUnixSocket(t *testing.T) {
+	socket := t.TempDir() + "/clamd.sock"
+	fakeClamd(t, "unix", socket)
+	result, err := scan.NewClamd("unix://"+socket).Scan(context.Background(), strings.NewReader("virus"))
+	require.NoError(t, err)
+	assert.True(t, result.Infected)
+}
+
+func TestClamdUnreachable(t *testing.T) {
+	_, err := scan.NewClamd("127.0.0.1:1").Scan(context.Background(), strings.NewReader("data"))
+	assert.Error(t, err)
+}
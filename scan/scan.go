@@ -0,0 +1,18 @@
+package scan
+
+import (
+	"context"
+	"io"
+)
+
+// Result is the verdict of a scan.
+type Result struct {
+	Infected  bool
+	Signature string
+}
+
+// Scanner inspects contents looking for malware. Implementations
+// must return an error when they cannot reach a verdict.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+}
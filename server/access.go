@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net"
+	"net/http"
+
+	"go.eloylp.dev/kit/http/middleware"
+
+	"go.eloylp.dev/go-serve/access"
+	"go.eloylp.dev/go-serve/config"
+)
+
+// realIP replaces the remote address of the request by the client
+// IP, as reported by the trusted proxies. This way, all the following
+// middlewares and handlers, including the request logger, see the same
+// client IP.
+func realIP(trusted config.IPNets) middleware.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.RemoteAddr = access.ClientIP(r, trusted)
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// ipFilter forbids the requests whose client IP is not allowed
+// by the rules.
+func ipFilter(rules config.IPRules) middleware.Middleware {
+	r := access.Rules{Allow: rules.Allow, Deny: rules.Deny}
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if !r.Allows(net.ParseIP(clientIP(req))) {
+				reply(w, http.StatusForbidden, http.StatusText(http.StatusForbidden))
+				return
+			}
+			h.ServeHTTP(w, req)
+		})
+	}
+}
+
+// accessMiddlewares returns the middlewares needed to resolve the
+// client IP and enforce the IP rules of an endpoint class.
+func accessMiddlewares(cfg *config.Settings, rules config.IPRules) []middleware.Middleware {
+	var result []middleware.Middleware
+	if len(cfg.TrustedProxies) > 0 {
+		result = append(result, realIP(cfg.TrustedProxies))
+	}
+	if rules.Enabled() {
+		result = append(result, ipFilter(rules))
+	}
+	return result
+}
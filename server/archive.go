@@ -0,0 +1,120 @@
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"os"
+	stdpath "path"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.eloylp.dev/go-serve/archivefs"
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/hidden"
+)
+
+// archiveSeparator separates the path of an archive
+// from the path of one of its members.
+const archiveSeparator = "/!/"
+
+// archives serves the members of the archives of the document root, at
+// the paths like /builds/v1.2.3.zip/!/docs/index.html, and the listings
+// of their directories, without extracting them. Archives are reached
+// through the document root file system, so hidden archives and the
+// ones behind forbidden symlinks are not found. The archive of a mount
+// is served at the root of its prefix instead. Any other request is
+// passed to the next handler.
+type archives struct {
+	logger   *logrus.Logger
+	fs       http.FileSystem
+	root     string
+	file     string
+	prefix   string
+	cache    *archivefs.Cache
+	hidden   *hidden.Matcher
+	template *template.Template
+	disabled []*regexp.Regexp
+	next     http.Handler
+}
+
+func (a *archives) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	base, file, ok := a.locate(r.URL.Path)
+	if !ok {
+		a.next.ServeHTTP(w, r)
+		return
+	}
+	archive, release, err := a.cache.Open(file)
+	if err != nil {
+		a.logger.WithError(err).Errorf("cannot open archive %s", file)
+		reply(w, http.StatusInternalServerError, "cannot open archive")
+		return
+	}
+	defer release()
+	fs := newDocRootFS("", &archiveFS{archive: archive, base: base}, nil, a.hidden)
+	served := &listings{fs: fs, prefix: a.prefix, template: a.template, disabled: a.disabled, next: http.FileServer(fs)}
+	served.ServeHTTP(w, r)
+}
+
+// locate returns the path the members of the requested archive are
+// under, and the archive on disk, if the path is inside a supported one.
+func (a *archives) locate(path string) (string, string, bool) {
+	if a.file != "" {
+		return "", a.file, true
+	}
+	i := strings.Index(path+"/", archiveSeparator)
+	if i < 0 {
+		return "", "", false
+	}
+	name := stdpath.Clean("/" + path[:i])
+	if !archivefs.Supported(name) {
+		return "", "", false
+	}
+	f, err := a.fs.Open(name)
+	if err != nil {
+		return "", "", false
+	}
+	info, err := f.Stat()
+	_ = f.Close()
+	if err != nil || !info.Mode().IsRegular() {
+		return "", "", false
+	}
+	return name + "/!", diskPath(a.root, name), true
+}
+
+// archiveFS is the file system of the members
+// of an archive, under the base path.
+type archiveFS struct {
+	archive *archivefs.Archive
+	base    string
+}
+
+func (a *archiveFS) Open(name string) (http.File, error) {
+	name = stdpath.Clean("/" + name)
+	if a.base != "" {
+		if name != a.base && !strings.HasPrefix(name, a.base+"/") {
+			return nil, os.ErrNotExist
+		}
+		name = "/" + strings.TrimPrefix(name, a.base)
+	}
+	return a.archive.Open(name)
+}
+
+// configureArchives serves the archives of the document root,
+// if enabled, before the requests reach the file server.
+func configureArchives(cfg *config.Settings, logger *logrus.Logger, fs http.FileSystem, docRoot string, cache *archivefs.Cache, hiddenFiles *hidden.Matcher, next http.Handler) http.Handler {
+	logger.Infof("configuring archives serving up to %d bytes", cfg.ArchivesMaxSize)
+	a := &archives{logger: logger, fs: fs, root: docRoot, prefix: cfg.Prefix, cache: cache, hidden: hiddenFiles, next: next}
+	a.template, _ = indexTemplate(cfg.ListingTemplate) // Already validated by New.
+	a.disabled, _ = listingDisabled(cfg.ListingDisabled)
+	return a
+}
+
+// archiveMount serves the archive of a mount at its prefix.
+func archiveMount(cfg *config.Settings, logger *logrus.Logger, m *config.Mount, file string, cache *archivefs.Cache, hiddenFiles *hidden.Matcher) http.Handler {
+	a := &archives{logger: logger, file: file, prefix: m.Prefix, cache: cache, hidden: hiddenFiles, next: http.NotFoundHandler()}
+	a.template, _ = indexTemplate(cfg.ListingTemplate) // Already validated by New.
+	a.disabled, _ = listingDisabled(m.ListingDisabled)
+	return a
+}
@@ -0,0 +1,275 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	stdpath "path"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.eloylp.dev/kit/http/middleware"
+
+	"go.eloylp.dev/go-serve/auth"
+	"go.eloylp.dev/go-serve/metrics"
+)
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	servedFileContextKey
+	targetsContextKey
+)
+
+// authenticators holds the authentication mechanisms shared by
+// all the endpoint classes of a host, the default one being empty.
+type authenticators struct {
+	logger        *logrus.Logger
+	host          string
+	tokens        *auth.TokenStore
+	signer        *auth.Signer
+	lockout       *auth.Lockout
+	read          middleware.Middleware
+	write         middleware.Middleware
+	authenticated []middleware.Middleware
+}
+
+func (a *authenticators) download() *authentication {
+	return &authentication{
+		logger:        a.logger,
+		host:          a.host,
+		tokens:        a.tokens,
+		lockout:       a.lockout,
+		signer:        a.signer,
+		basic:         a.read,
+		authenticated: a.authenticated,
+		action:        auth.ActionDownload,
+		target:        downloadPath,
+		publicPath:    func(r *http.Request) string { return r.URL.Path },
+		unsigned:      DownloadPathHeader,
+	}
+}
+
+func (a *authenticators) upload() *authentication {
+	return &authentication{
+		logger:        a.logger,
+		host:          a.host,
+		tokens:        a.tokens,
+		lockout:       a.lockout,
+		basic:         a.write,
+		authenticated: a.authenticated,
+		required:      a.tokens != nil,
+		action:        auth.ActionUpload,
+		target:        func(r *http.Request) string { return r.Header.Get(DeployPathHeader) },
+	}
+}
+
+// files authenticates the requests to the file server, whose paths
+// are already relative to the document root.
+func (a *authenticators) files(prefix string) *authentication {
+	return &authentication{
+		logger:        a.logger,
+		host:          a.host,
+		tokens:        a.tokens,
+		lockout:       a.lockout,
+		signer:        a.signer,
+		basic:         a.read,
+		authenticated: a.authenticated,
+		action:        auth.ActionRead,
+		target:        func(r *http.Request) string { return r.URL.Path },
+		publicPath:    func(r *http.Request) string { return prefix + r.URL.Path },
+	}
+}
+
+// authentication describes how requests to a single endpoint class
+// are authenticated. Signed URLs are checked against the host and public
+// path of the request and bearer tokens against its host, action and
+// target path.
+// Signed requests carrying the unsigned header, which would change their
+// target without invalidating the signature, are rejected.
+// Any other request is delegated to the basic auth checker, if configured.
+// The authenticated middlewares run only after a successful authentication.
+type authentication struct {
+	logger        *logrus.Logger
+	host          string
+	tokens        *auth.TokenStore
+	signer        *auth.Signer
+	lockout       *auth.Lockout
+	basic         middleware.Middleware
+	authenticated []middleware.Middleware
+	required      bool
+	action        string
+	target        func(r *http.Request) string
+	publicPath    func(r *http.Request) string
+	unsigned      string
+}
+
+func (a *authentication) enabled() bool {
+	return a.basic != nil || a.required
+}
+
+func (a *authentication) Middleware() middleware.Middleware {
+	return func(h http.Handler) http.Handler {
+		basic := h
+		if a.basic != nil {
+			basic = a.guard(a.basic(basicUser(h)))
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if query := r.URL.Query(); a.signer != nil && auth.IsSigned(query) {
+				var done func(used bool)
+				var err error
+				if a.unsigned != "" && r.Header.Get(a.unsigned) != "" {
+					err = fmt.Errorf("%s header is not covered by the signature", a.unsigned)
+				} else {
+					done, err = a.signer.Claim(a.host, a.publicPath(r), query, clientIP(r))
+				}
+				if err != nil {
+					a.logger.WithError(err).Warnf("signed URL authentication failed for %s", a.action)
+					authFailed(r, "signature")
+					unauthorized(w)
+					return
+				}
+				// Single use signatures are only consumed by complete
+				// successful responses, not by rejected or failed ones.
+				// Signatures only cover the requested path.
+				signed := stdpath.Clean("/" + a.target(r))
+				r = withTargets(r, func(target string) bool { return stdpath.Clean("/"+target) == signed })
+				rec := &statusRecorder{ResponseWriter: w}
+				h.ServeHTTP(rec, r)
+				done(rec.succeeded() && r.Context().Err() == nil)
+				return
+			}
+			if secret, ok := bearerToken(r); ok && a.tokens != nil {
+				token, err := a.tokens.Authenticate(secret, a.host, a.action, a.target(r))
+				if err != nil {
+					a.logger.WithError(err).Warnf("token authentication failed for %s", a.action)
+					authFailed(r, "token")
+					unauthorized(w)
+					return
+				}
+				r = withTargets(r, func(target string) bool { return token.Allows(a.host, a.action, target) })
+				h.ServeHTTP(w, withUser(r, "token:"+token.ID))
+				return
+			}
+			if a.basic == nil {
+				unauthorized(w)
+				return
+			}
+			basic.ServeHTTP(w, r)
+		})
+	}
+}
+
+// guard tracks the failures of the basic auth checker. If lockout is
+// configured, requests from locked out client IPs or users are rejected
+// before checking their credentials, as bcrypt checks are expensive.
+func (a *authentication) guard(checker http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := lockoutKeys(r)
+		if a.lockout != nil {
+			for _, k := range keys {
+				if locked, remaining := a.lockout.Locked(k); locked {
+					tooManyRequests(w, r, "lockout", remaining)
+					return
+				}
+			}
+		}
+		rec := &statusRecorder{ResponseWriter: w}
+		checker.ServeHTTP(rec, r)
+		if rec.status != http.StatusUnauthorized {
+			// Successful logins forget the failures of the user, but not
+			// the ones of the client IP, which could be guessing other users.
+			if a.lockout != nil && len(keys) > 1 {
+				a.lockout.Succeed(keys[1])
+			}
+			return
+		}
+		authFailed(r, "basic")
+		if a.lockout == nil {
+			return
+		}
+		for _, k := range keys {
+			if locked, d := a.lockout.Fail(k); locked {
+				a.logger.WithField("key", k).Warnf("authentication lockout triggered for %s during %s", k, d)
+			}
+		}
+	})
+}
+
+// lockoutKeys returns the client IP key, followed by the
+// user key if the request carries basic auth credentials.
+func lockoutKeys(r *http.Request) []string {
+	keys := []string{"ip:" + clientIP(r)}
+	if user, _, ok := r.BasicAuth(); ok {
+		keys = append(keys, "user:"+user)
+	}
+	return keys
+}
+
+func authFailed(r *http.Request, mechanism string) {
+	if metrics.AuthFailures != nil {
+		metrics.AuthFailures.WithLabelValues(metrics.Labels(r.Context(), mechanism)...).Inc()
+	}
+}
+
+// basicUser records the basic auth user as the authenticated one.
+// It must only wrap handlers reached after a successful basic
+// auth check.
+func basicUser(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, ok := r.BasicAuth(); ok {
+			r = withUser(r, user)
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func withUser(r *http.Request, user string) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), userContextKey, user))
+}
+
+// requestUser returns the identity of the authenticated user of the
+// request. API tokens are identified by their ID.
+func requestUser(r *http.Request) (string, bool) {
+	user, ok := r.Context().Value(userContextKey).(string)
+	return user, ok
+}
+
+// withTargets keeps the check of the targets the authenticated request
+// is allowed to reach, for the handlers that change its target path.
+func withTargets(r *http.Request, allowed func(target string) bool) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), targetsContextKey, allowed))
+}
+
+// targetAllowed reports whether the request is allowed to reach the
+// target path, which requests authenticated by basic auth or not
+// authenticated at all always are.
+func targetAllowed(r *http.Request, target string) bool {
+	allowed, ok := r.Context().Value(targetsContextKey).(func(target string) bool)
+	return !ok || allowed(target)
+}
+
+// clientIP returns the IP address of the peer that made the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	h := r.Header.Get("Authorization")
+	if len(h) <= len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+		return "", false
+	}
+	return h[len(prefix):], true
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="go-serve"`)
+	reply(w, http.StatusUnauthorized, http.StatusText(http.StatusUnauthorized))
+}
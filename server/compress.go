@@ -0,0 +1,264 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	stdpath "path"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"go.eloylp.dev/kit/http/middleware"
+
+	"go.eloylp.dev/go-serve/compression"
+	"go.eloylp.dev/go-serve/metrics"
+)
+
+// compressor compresses on the fly the successful responses of
+// compressible types above the minimum size, with the most preferred
+// encoding accepted by the client. Responses already encoded, like
+// precompressed sidecars, and partial ones are left untouched.
+// Compressed static files, whose modification time is recorded by the
+// file server and which have a known length, are cached by path, exact
+// modification time and size. The path is the requested one, prefix
+// included, as the file server ones are relative to the prefixes of the
+// document root and the mounts.
+type compressor struct {
+	logger    *logrus.Logger
+	encodings []string
+	types     []string
+	minSize   int64
+	cache     *compression.Cache
+}
+
+func (c *compressor) middleware() middleware.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodHead || r.Header.Get("Range") != "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			cw := &compressWriter{ResponseWriter: w, c: c, r: r}
+			if c.cache != nil {
+				cw.file = &servedFile{}
+				r = r.WithContext(context.WithValue(r.Context(), servedFileContextKey, cw.file))
+			}
+			defer cw.finish()
+			h.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// servedFile is where the file server records the modification time of
+// the file it serves, as the Last-Modified header only has a resolution
+// of one second, which would not tell apart the files changed within it.
+type servedFile struct {
+	modTime int64
+}
+
+// recordModTime records the modification time, in nanoseconds, of the
+// files served by the next handler, for the compressor cache.
+func recordModTime(fs http.FileSystem, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if file, ok := r.Context().Value(servedFileContextKey).(*servedFile); ok {
+			if f, err := fs.Open(stdpath.Clean("/" + r.URL.Path)); err == nil {
+				if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+					file.modTime = info.ModTime().UnixNano()
+				}
+				_ = f.Close()
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// encoding returns the most preferred encoding accepted by the
+// client, or an empty string if none of them is accepted.
+func (c *compressor) encoding(r *http.Request) string {
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	var result string
+	var best float64
+	for _, e := range c.encodings {
+		if q := accepted.quality(e); q > best {
+			result, best = e, q
+		}
+	}
+	return result
+}
+
+// compressWriter buffers the beginning of a successful response until
+// it can decide whether to compress it, which happens once the length
+// is known or the minimum size is reached.
+type compressWriter struct {
+	http.ResponseWriter
+	c       *compressor
+	r       *http.Request
+	status  int
+	decided bool
+	buf     bytes.Buffer
+	out     io.Writer
+	encoder io.WriteCloser
+	capture *limitedBuffer
+	file    *servedFile
+	key     string
+}
+
+func (w *compressWriter) WriteHeader(code int) {
+	if w.status != 0 {
+		return
+	}
+	w.status = code
+	if code != http.StatusOK {
+		w.passthrough()
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.decided {
+		return w.out.Write(b)
+	}
+	w.buf.Write(b)
+	if w.Header().Get("Content-Length") != "" || int64(w.buf.Len()) >= w.c.minSize {
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide compresses the response, from the cache if possible,
+// or writes it as is, flushing the buffered content.
+func (w *compressWriter) decide() error {
+	w.decided = true
+	h := w.Header()
+	if h.Get("Content-Type") == "" {
+		h.Set("Content-Type", http.DetectContentType(w.buf.Bytes()))
+	}
+	size := int64(w.buf.Len())
+	if cl, err := strconv.ParseInt(h.Get("Content-Length"), 10, 64); err == nil {
+		size = cl
+	}
+	if h.Get("Content-Encoding") != "" || size < w.c.minSize || !compression.Compressible(h.Get("Content-Type"), w.c.types) {
+		w.passthrough()
+		return nil
+	}
+	addVary(h, "Accept-Encoding")
+	encoding := w.c.encoding(w.r)
+	if encoding == "" {
+		w.passthrough()
+		return nil
+	}
+	if w.file != nil && w.file.modTime != 0 && h.Get("Content-Length") != "" {
+		modTime := strconv.FormatInt(w.file.modTime, 10)
+		w.key = strings.Join([]string{requestPath(w.r), modTime, h.Get("Content-Length"), h.Get("ETag"), encoding}, "|")
+		if data, ok := w.c.cache.Get(w.key); ok {
+			cacheLookup(w.r, "hit")
+			w.encoded(encoding)
+			h.Set("Content-Length", strconv.Itoa(len(data)))
+			w.ResponseWriter.WriteHeader(w.status)
+			_, err := w.ResponseWriter.Write(data)
+			w.out = io.Discard
+			return err
+		}
+		cacheLookup(w.r, "miss")
+		w.capture = &limitedBuffer{max: w.c.cache.Max()}
+	}
+	w.encoded(encoding)
+	w.ResponseWriter.WriteHeader(w.status)
+	out := io.Writer(w.ResponseWriter)
+	if w.capture != nil {
+		out = io.MultiWriter(w.ResponseWriter, w.capture)
+	}
+	encoder, err := compression.NewWriter(out, encoding)
+	if err != nil {
+		return err
+	}
+	w.encoder, w.out = encoder, encoder
+	_, err = w.buf.WriteTo(encoder)
+	return err
+}
+
+// encoded sets the headers of a response compressed with the encoding.
+// Ranges are not supported, as they would apply to the encoded content.
+// Strong ETags become weak, as the encoded content is not byte for byte
+// the same, while still matching the If-None-Match of the clients.
+func (w *compressWriter) encoded(encoding string) {
+	h := w.Header()
+	h.Set("Content-Encoding", encoding)
+	h.Del("Content-Length")
+	h.Del("Accept-Ranges")
+	if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+		h.Set("ETag", "W/"+etag)
+	}
+}
+
+func (w *compressWriter) passthrough() {
+	w.decided = true
+	w.out = w.ResponseWriter
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.buf.WriteTo(w.ResponseWriter)
+}
+
+// finish flushes the response once the handler returns,
+// caching the compressed content if needed.
+func (w *compressWriter) finish() {
+	if w.status == 0 {
+		return
+	}
+	if !w.decided {
+		if err := w.decide(); err != nil {
+			w.c.logger.WithError(err).Error("cannot compress response")
+			return
+		}
+	}
+	if w.encoder == nil {
+		return
+	}
+	if err := w.encoder.Close(); err != nil {
+		w.c.logger.WithError(err).Error("cannot compress response")
+		return
+	}
+	if w.capture != nil && !w.capture.overflow {
+		w.c.cache.Put(w.key, w.capture.buf.Bytes())
+	}
+}
+
+// limitedBuffer stops buffering once the content exceeds the
+// maximum, as it could not be cached, but never fails.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	max      int64
+	overflow bool
+}
+
+func (l *limitedBuffer) Write(b []byte) (int, error) {
+	if l.overflow || int64(l.buf.Len()+len(b)) > l.max {
+		l.overflow = true
+		l.buf.Reset()
+		return len(b), nil
+	}
+	return l.buf.Write(b)
+}
+
+// addVary adds the header to the Vary ones, unless already there.
+func addVary(h http.Header, header string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(f), header) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", header)
+}
+
+func cacheLookup(r *http.Request, result string) {
+	if metrics.CompressionCache != nil {
+		metrics.CompressionCache.WithLabelValues(metrics.Labels(r.Context(), result)...).Inc()
+	}
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"go.eloylp.dev/kit/http/middleware"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/cors"
+)
+
+// corsPolicy answers the preflight requests and sets the CORS headers
+// of the actual ones. It must run before authentication, as browsers
+// never send credentials on preflight requests, and so the allowed
+// origins can read the authentication errors too.
+func corsPolicy(c *cors.CORS) middleware.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !cors.IsPreflight(r) {
+				c.Apply(r, w.Header())
+				h.ServeHTTP(w, r)
+				return
+			}
+			if !c.Preflight(r, w.Header()) {
+				reply(w, http.StatusForbidden, "CORS request not allowed")
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// corsFrom builds the CORS policy of an endpoint class, whose
+// methods are allowed if the policy does not set them.
+func corsFrom(policy config.CORSPolicy, methods ...string) (*cors.CORS, error) {
+	if len(policy.AllowMethods) > 0 {
+		methods = policy.AllowMethods
+	}
+	return cors.New(cors.Policy{
+		AllowOrigins:     policy.AllowOrigins,
+		AllowMethods:     methods,
+		AllowHeaders:     policy.AllowHeaders,
+		ExposeHeaders:    policy.ExposeHeaders,
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           policy.MaxAge,
+	})
+}
+
+// withCORS returns a copy of the provided middlewares, with the
+// CORS one appended if the policy is enabled.
+func withCORS(middlewares []middleware.Middleware, policy config.CORSPolicy, methods ...string) []middleware.Middleware {
+	if !policy.Enabled() {
+		return middlewares
+	}
+	c, _ := corsFrom(policy, methods...) // Already validated by New.
+	return chain(middlewares, corsPolicy(c))
+}
+
+// optionsHandler replies the OPTIONS requests that are not
+// preflight ones with the methods of the endpoint.
+func optionsHandler(methods ...string) http.Handler {
+	allow := strings.Join(append([]string{http.MethodOptions}, methods...), ", ")
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Allow", allow)
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,176 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"html/template"
+	"io"
+	"net/http"
+	stdpath "path"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.eloylp.dev/kit/http/middleware"
+)
+
+// errorCodes are the stable codes of the error responses
+// that can be replaced by error pages or JSON bodies.
+var errorCodes = map[int]string{
+	http.StatusUnauthorized:          "unauthorized",
+	http.StatusForbidden:             "forbidden",
+	http.StatusNotFound:              "not_found",
+	http.StatusRequestEntityTooLarge: "payload_too_large",
+	http.StatusTooManyRequests:       "too_many_requests",
+	http.StatusInternalServerError:   "internal_error",
+}
+
+// APIError is the body of the error responses to the
+// clients that prefer JSON, under the "error" key.
+type APIError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ErrorPage is the data the error page templates are executed with.
+type ErrorPage struct {
+	APIError
+	Path string
+}
+
+// errorPages replaces the bodies of error responses. Clients that
+// prefer JSON get an APIError. Otherwise, if an error pages file
+// system is set, the <status>.html template closest to the request
+// path is rendered, looking up from its directory to the root. Other
+// responses keep their original bodies.
+type errorPages struct {
+	fs    http.FileSystem
+	mu    sync.Mutex
+	cache map[string]*cachedPage
+}
+
+type cachedPage struct {
+	modTime  time.Time
+	template *template.Template
+}
+
+func newErrorPages(fs http.FileSystem) *errorPages {
+	return &errorPages{fs: fs, cache: map[string]*cachedPage{}}
+}
+
+func (e *errorPages) middleware() middleware.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h.ServeHTTP(&errorWriter{ResponseWriter: w, pages: e, r: r}, r)
+		})
+	}
+}
+
+// render returns the body and content type that replace the
+// error response to the request, if any.
+func (e *errorPages) render(r *http.Request, status int) ([]byte, string, bool) {
+	apiErr := APIError{Status: status, Code: errorCodes[status], Message: http.StatusText(status)}
+	if acceptsJSON(r) {
+		body, err := json.Marshal(map[string]APIError{"error": apiErr})
+		if err != nil {
+			return nil, "", false
+		}
+		return append(body, '\n'), ContentTypeJSON, true
+	}
+	if e.fs == nil {
+		return nil, "", false
+	}
+	t := e.lookup(stdpath.Dir(stdpath.Clean("/"+r.URL.Path)), status)
+	if t == nil {
+		return nil, "", false
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, &ErrorPage{APIError: apiErr, Path: r.URL.Path}); err != nil {
+		return nil, "", false
+	}
+	return b.Bytes(), "text/html; charset=utf-8", true
+}
+
+// lookup returns the template of the status page closest to the
+// directory, which is parsed again only if it was modified.
+func (e *errorPages) lookup(dir string, status int) *template.Template {
+	name := strconv.Itoa(status) + ".html"
+	for {
+		path := stdpath.Join(dir, name)
+		if t, ok := e.page(path); ok {
+			return t
+		}
+		if dir == "/" {
+			return nil
+		}
+		dir = stdpath.Dir(dir)
+	}
+}
+
+func (e *errorPages) page(path string) (*template.Template, bool) {
+	f, err := e.fs.Open(path)
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return nil, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if c, ok := e.cache[path]; ok && c.modTime.Equal(info.ModTime()) {
+		return c.template, true
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, false
+	}
+	t, err := template.New(path).Parse(string(data))
+	if err != nil {
+		return nil, false
+	}
+	e.cache[path] = &cachedPage{modTime: info.ModTime(), template: t}
+	return t, true
+}
+
+// errorWriter replaces the body of the error responses written
+// through it, discarding the original one.
+type errorWriter struct {
+	http.ResponseWriter
+	pages       *errorPages
+	r           *http.Request
+	wroteHeader bool
+	replaced    bool
+}
+
+func (w *errorWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if _, ok := errorCodes[code]; ok {
+		if body, contentType, ok := w.pages.render(w.r, code); ok {
+			w.replaced = true
+			h := w.Header()
+			h.Del("Content-Length")
+			h.Set("Content-Type", contentType)
+			h.Set("X-Content-Type-Options", "nosniff")
+			w.ResponseWriter.WriteHeader(code)
+			_, _ = w.ResponseWriter.Write(body)
+			return
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *errorWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
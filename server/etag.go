@@ -0,0 +1,62 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"os"
+	stdpath "path"
+	"path/filepath"
+
+	"go.eloylp.dev/go-serve/digest"
+)
+
+// etags sets the strong ETag of the requested files, derived from
+// their content, before passing the requests to the next handler.
+// The file server then replies to the conditional requests, like
+// the ones with If-None-Match, with the ETag.
+func etags(fs http.FileSystem, root string, digests *digest.Cache, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := stdpath.Clean("/" + r.URL.Path)
+		if f, err := fs.Open(name); err == nil {
+			if info, err := f.Stat(); err == nil && info.Mode().IsRegular() {
+				_ = setETag(w, digests, diskPath(root, name), info, f)
+			}
+			_ = f.Close()
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// setETag sets the ETag of the file, with the provided info, from the
+// cached sum or its content, leaving the file at its beginning.
+func setETag(w http.ResponseWriter, digests *digest.Cache, path string, info os.FileInfo, f io.ReadSeeker) error {
+	if digests == nil {
+		return nil
+	}
+	sum, err := digests.Sum(path, info, f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	w.Header().Set("ETag", digest.ETag(sum))
+	return nil
+}
+
+// diskPath returns the path in the file system of
+// the slash separated name, relative to the root.
+func diskPath(root, name string) string {
+	return filepath.Join(root, filepath.FromSlash(name))
+}
+
+// digestLookup returns the lookup of the already computed content
+// hashes of the files under the root, for the directory listings.
+func digestLookup(root string, digests *digest.Cache) hashLookup {
+	if digests == nil {
+		return nil
+	}
+	return func(name string, info os.FileInfo) (string, bool) {
+		return digests.Lookup(diskPath(root, name), info)
+	}
+}
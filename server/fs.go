@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	stdpath "path"
+
+	"go.eloylp.dev/go-serve/hidden"
+	"go.eloylp.dev/go-serve/symlink"
+)
+
+// docRootFS is the http.FileSystem of the document root, read from its
+// storage, or of any other file system, like the ones of archives. It
+// hides the files matching the hidden patterns and the ones reached
+// through symlinks not allowed by the guard, if any. They are reported
+// as not existing, so their existence is not revealed, and omitted
+// from listings.
+type docRootFS struct {
+	root   string
+	fs     http.FileSystem
+	guard  *symlink.Guard
+	hidden *hidden.Matcher
+}
+
+func newDocRootFS(root string, fs http.FileSystem, guard *symlink.Guard, hidden *hidden.Matcher) *docRootFS {
+	return &docRootFS{root: root, fs: fs, guard: guard, hidden: hidden}
+}
+
+func (d *docRootFS) Open(name string) (http.File, error) {
+	name = stdpath.Clean("/" + name)
+	if d.hidden.Hidden(name) {
+		return nil, os.ErrNotExist
+	}
+	if d.guard != nil && d.guard.Check(diskPath(d.root, name)) != nil {
+		return nil, os.ErrNotExist
+	}
+	f, err := d.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &docRootFile{File: f, fs: d, name: name}, nil
+}
+
+// visible reports whether the file at name, a slash separated
+// path relative to the root, can be shown in listings.
+func (d *docRootFS) visible(name string, info os.FileInfo) bool {
+	if d.hidden.Hidden(name) {
+		return false
+	}
+	return info.Mode()&os.ModeSymlink == 0 || d.guard == nil || d.guard.Permits(diskPath(d.root, name))
+}
+
+type docRootFile struct {
+	http.File
+	fs   *docRootFS
+	name string
+}
+
+func (f *docRootFile) Readdir(count int) ([]os.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	result := infos[:0]
+	for _, info := range infos {
+		if f.fs.visible(stdpath.Join(f.name, info.Name()), info) {
+			result = append(result, info)
+		}
+	}
+	return result, err
+}
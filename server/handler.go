@@ -1,24 +1,40 @@
 package server
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	stdpath "path"
 	"path/filepath"
+	"time"
 
 	"github.com/sirupsen/logrus"
-	"go.eloylp.dev/kit/archive"
 	"go.eloylp.dev/kit/pathutil"
 
+	"go.eloylp.dev/go-serve/audit"
+	"go.eloylp.dev/go-serve/digest"
+	"go.eloylp.dev/go-serve/extract"
+	"go.eloylp.dev/go-serve/hidden"
 	"go.eloylp.dev/go-serve/metrics"
+	"go.eloylp.dev/go-serve/pack"
+	"go.eloylp.dev/go-serve/scan"
+	"go.eloylp.dev/go-serve/storage"
+	"go.eloylp.dev/go-serve/symlink"
+	"go.eloylp.dev/go-serve/upload"
 )
 
 const (
 	ContentTypeTarGzip = "application/tar+gzip"
 	ContentTypeFile    = "application/octet-stream"
+	ContentTypeJSON    = "application/json"
 	DeployPathHeader   = "GoServe-Deploy-Path"
+	DownloadPathHeader = "GoServe-Download-Path"
 )
 
 func StatusHandler(info Info) http.HandlerFunc {
@@ -34,80 +50,415 @@ func StatusHandler(info Info) http.HandlerFunc {
 	}
 }
 
-func UploadHandler(logger *logrus.Logger, docRoot string) http.HandlerFunc {
+// UploadOption configures optional behaviors of the UploadHandler.
+type UploadOption func(o *uploadOptions)
+
+type uploadOptions struct {
+	audit       audit.Sink
+	policy      *upload.Policy
+	stagingDir  string
+	scanner     scan.Scanner
+	scanTimeout time.Duration
+	failOpen    bool
+	extract     *extract.Policy
+	guard       *symlink.Guard
+	digests     *digest.Cache
+	storage     storage.Storage
+}
+
+// WithAuditSink makes the UploadHandler record an audit event
+// for every upload, successful or not.
+func WithAuditSink(sink audit.Sink) UploadOption {
+	return func(o *uploadOptions) {
+		o.audit = sink
+	}
+}
+
+// WithUploadPolicy makes the UploadHandler reject the uploads
+// containing files not allowed by the policy.
+func WithUploadPolicy(policy *upload.Policy) UploadOption {
+	return func(o *uploadOptions) {
+		o.policy = policy
+	}
+}
+
+// WithStagingDir sets where uploads are written before being
+// moved to the document root.
+func WithStagingDir(dir string) UploadOption {
+	return func(o *uploadOptions) {
+		o.stagingDir = dir
+	}
+}
+
+// WithExtractPolicy sets the policy applied to the entries of
+// the uploaded archives, instead of the default one.
+func WithExtractPolicy(policy *extract.Policy) UploadOption {
+	return func(o *uploadOptions) {
+		o.extract = policy
+	}
+}
+
+// WithUploadGuard makes the UploadHandler reject the uploads whose
+// targets are reached through symlinks not allowed by the guard.
+func WithUploadGuard(guard *symlink.Guard) UploadOption {
+	return func(o *uploadOptions) {
+		o.guard = guard
+	}
+}
+
+// WithDigests makes the UploadHandler compute the content hashes of
+// the uploaded files, so they are ready for the file server ETags.
+func WithDigests(digests *digest.Cache) UploadOption {
+	return func(o *uploadOptions) {
+		o.digests = digests
+	}
+}
+
+// WithUploadStorage makes the UploadHandler write the uploads to the
+// storage, instead of the local directory of the document root.
+func WithUploadStorage(s storage.Storage) UploadOption {
+	return func(o *uploadOptions) {
+		o.storage = s
+	}
+}
+
+// WithScanner makes the UploadHandler scan the uploaded files for malware
+// before moving them to the document root. If failOpen is true, uploads are
+// accepted when the scanner cannot reach a verdict. Otherwise, they are rejected.
+func WithScanner(scanner scan.Scanner, timeout time.Duration, failOpen bool) UploadOption {
+	return func(o *uploadOptions) {
+		o.scanner = scanner
+		o.scanTimeout = timeout
+		o.failOpen = failOpen
+	}
+}
+
+func UploadHandler(logger *logrus.Logger, docRoot string, opts ...UploadOption) http.HandlerFunc {
+	o := &uploadOptions{storage: storage.NewLocal(docRoot)}
+	for _, opt := range opts {
+		opt(o)
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		deployPath := r.Header.Get(DeployPathHeader)
+		event := uploadEvent(r, deployPath)
+		defer o.record(logger, event)
 		path := filepath.Join(docRoot, deployPath) // nolinter: gosec
 		if err := pathutil.PathInRoot(docRoot, path); err != nil {
 			logger.WithError(err).Error("upload path violation try")
+			event.Fail(err)
 			reply(w, http.StatusBadRequest, err.Error())
 			return
 		}
 		absPath, err := filepath.Abs(path)
 		if err != nil {
 			logger.WithError(err).Error("error determining absolute path for upload")
+			event.Fail(err)
+			reply(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if o.guard != nil {
+			if err := o.guard.Check(absPath); err != nil {
+				logger.WithError(err).Warn("upload path through forbidden symlink")
+				event.Fail(err)
+				reply(w, http.StatusForbidden, err.Error())
+				return
+			}
+		}
+		contentType := r.Header.Get("Content-Type")
+		if contentType != ContentTypeTarGzip && contentType != ContentTypeFile {
+			event.Fail(errors.New("unsupported content type"))
+			http.NotFound(w, r)
+			return
+		}
+		name := storage.Clean(filepath.ToSlash(deployPath))
+		if contentType == ContentTypeFile && name == "/" {
+			event.Fail(errors.New("file uploads need a file name"))
+			reply(w, http.StatusBadRequest, "file uploads need a file name")
+			return
+		}
+		stage, err := upload.NewStage(o.stagingDir)
+		if err != nil {
+			logger.WithError(err).Error("cannot create upload stage")
+			event.Fail(err)
+			reply(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+			return
+		}
+		defer func() {
+			if err := stage.Remove(); err != nil {
+				logger.WithError(err).Error("cannot remove upload stage")
+			}
+		}()
+		report, dst, root, err := o.receive(r, event, stage, contentType, name, deployPath)
+		if err != nil {
+			logger.Debugf("%v", err)
+			event.Fail(err)
+			var rejected *extract.RejectedError
+			if errors.As(err, &rejected) {
+				replyRejected(w, err)
+				return
+			}
 			reply(w, http.StatusBadRequest, err.Error())
 			return
 		}
-		var writtenBytes int64
-		switch r.Header.Get("Content-Type") {
-		case ContentTypeTarGzip:
-			writtenBytes, err = archive.ExtractTARGZ(r.Body, absPath)
-			if err != nil {
-				logger.Debugf("%v", err)
-				reply(w, http.StatusBadRequest, err.Error())
+		if o.policy != nil {
+			if err := o.policy.Verify(stage.Dir(), root, report.Files); err != nil {
+				logger.WithError(err).Warn("upload rejected by policy")
+				event.Fail(err)
+				replyRejected(w, err)
 				return
 			}
-		case ContentTypeFile:
-			writtenBytes, err = saveFile(r.Body, path)
-			if err != nil {
-				logger.Debugf("%v", err)
-				reply(w, http.StatusBadRequest, err.Error())
+		}
+		if err := o.scan(r.Context(), logger, stage, root, report); err != nil {
+			var malwareErr *upload.MalwareError
+			if !errors.As(err, &malwareErr) {
+				logger.WithError(err).Error("cannot scan upload")
+				event.Fail(err)
+				reply(w, http.StatusServiceUnavailable, "upload could not be scanned")
 				return
 			}
-		default:
-			http.NotFound(w, r)
+			logger.WithError(err).Warn("upload rejected by malware scanner")
+			event.Fail(err)
+			replyRejected(w, err)
+			return
+		}
+		var check func(string) error
+		if o.guard != nil {
+			check = func(name string) error {
+				return o.guard.Check(diskPath(docRoot, name))
+			}
+		}
+		if err := stage.Commit(o.storage, dst, report, check); err != nil {
+			logger.WithError(err).Error("cannot commit upload")
+			event.Fail(err)
+			reply(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
 			return
 		}
-		msg := fmt.Sprintf("upload complete ! Bytes written: %d", writtenBytes)
+		if o.digests != nil {
+			for _, f := range report.Files {
+				if err := o.precompute(docRoot, stdpath.Join(dst, f.Path)); err != nil {
+					logger.WithError(err).Warn("cannot compute content hash of uploaded file")
+				}
+			}
+		}
+		event.Files = docRootFiles(root, report.Files)
+		event.Outcome = audit.OutcomeSuccess
+		msg := fmt.Sprintf("upload complete ! Bytes written: %d", report.Bytes)
 		logger.Debug(msg)
+		for _, d := range report.Decisions {
+			msg += fmt.Sprintf("\n%s %s entry %s", d.Action, d.Case, d.Path)
+		}
 		if metrics.UploadSize != nil {
-			metrics.UploadSize.WithLabelValues().Observe(float64(writtenBytes))
+			metrics.UploadSize.WithLabelValues(metrics.Labels(r.Context())...).Observe(float64(report.Bytes))
 		}
 		reply(w, http.StatusOK, msg)
 	}
 }
 
-func saveFile(reader io.Reader, path string) (int64, error) {
-	dir := filepath.Dir(path)
-	if err := os.MkdirAll(dir, 0755); err != nil { //nolint: gomnd
-		return 0, err
+// receive writes the request body in the stage. It returns the report of
+// the written files, the storage directory they must be moved to and its
+// path relative to the document root. Single files are staged with the
+// name of the deploy path, so they are moved to its parent directory.
+func (o *uploadOptions) receive(r *http.Request, event *audit.Event, stage *upload.Stage, contentType, name, deployPath string) (*extract.Report, string, string, error) {
+	checksum := sha256.New()
+	body := io.TeeReader(r.Body, checksum)
+	var report *extract.Report
+	var err error
+	dst, root := name, deployPath
+	if contentType == ContentTypeTarGzip {
+		report, err = extract.TARGZ(body, stage.Dir(), o.extract)
+	} else {
+		dst, root = stdpath.Dir(name), stdpath.Dir(stdpath.Join("/", deployPath))
+		report, err = saveFile(body, filepath.Join(stage.Dir(), stdpath.Base(name)))
+	}
+	// Consume what is left of the body, like archive padding,
+	// so the checksum covers the whole upload.
+	_, _ = io.Copy(io.Discard, body)
+	event.Bytes = report.Bytes
+	event.SHA256 = hex.EncodeToString(checksum.Sum(nil))
+	return report, dst, root, err
+}
+
+// precompute computes the content hash of the uploaded file, if it is
+// a regular one, keyed by its path in the document root, like the file
+// server ETags are.
+func (o *uploadOptions) precompute(docRoot, name string) error {
+	info, err := o.storage.Stat(name)
+	if err != nil || !info.Mode().IsRegular() {
+		return err
 	}
+	f, err := o.storage.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if info, err = f.Stat(); err != nil {
+		return err
+	}
+	root, err := filepath.Abs(docRoot)
+	if err != nil {
+		return err
+	}
+	_, err = o.digests.Sum(diskPath(root, name), info, f)
+	return err
+}
+
+// scan scans the staged files, if a scanner is configured. Scanner
+// failures are only returned when failing closed.
+func (o *uploadOptions) scan(ctx context.Context, logger *logrus.Logger, stage *upload.Stage, root string, report *extract.Report) error {
+	if o.scanner == nil {
+		return nil
+	}
+	if o.scanTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.scanTimeout)
+		defer cancel()
+	}
+	err := upload.Scan(ctx, o.scanner, stage.Dir(), root, report.Files)
+	var malwareErr *upload.MalwareError
+	if err != nil && o.failOpen && !errors.As(err, &malwareErr) {
+		logger.WithError(err).Warn("cannot scan upload, accepting it unscanned")
+		return nil
+	}
+	return err
+}
+
+// replyRejected replies with the list of files that caused
+// the rejection of an upload.
+func replyRejected(w http.ResponseWriter, err error) {
+	var policyErr *upload.PolicyError
+	var malwareErr *upload.MalwareError
+	var rejected *extract.RejectedError
+	switch {
+	case errors.As(err, &policyErr):
+		replyJSON(w, http.StatusUnsupportedMediaType, map[string]interface{}{
+			"error":      "upload policy violation",
+			"violations": policyErr.Violations,
+		})
+	case errors.As(err, &rejected):
+		replyJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":     "extraction policy violation",
+			"decisions": rejected.Decisions,
+		})
+	case errors.As(err, &malwareErr):
+		replyJSON(w, http.StatusUnprocessableEntity, map[string]interface{}{
+			"error":      "malware detected",
+			"infections": malwareErr.Infections,
+		})
+	default:
+		reply(w, http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+	}
+}
+
+func uploadEvent(r *http.Request, deployPath string) *audit.Event {
+	user, _ := requestUser(r)
+	return &audit.Event{
+		Time:        time.Now().UTC(),
+		Action:      "upload",
+		User:        user,
+		ClientIP:    clientIP(r),
+		Path:        deployPath,
+		ContentType: r.Header.Get("Content-Type"),
+	}
+}
+
+func (o *uploadOptions) record(logger *logrus.Logger, event *audit.Event) {
+	if o.audit == nil {
+		return
+	}
+	if err := o.audit.Record(event); err != nil {
+		logger.WithError(err).Error("cannot record upload audit event")
+	}
+}
+
+// docRootFiles makes the paths of the files relative to the
+// document root, instead of relative to the upload root.
+func docRootFiles(root string, files []extract.File) []extract.File {
+	result := make([]extract.File, 0, len(files))
+	for _, f := range files {
+		f.Path = stdpath.Join("/", root, f.Path)
+		result = append(result, f)
+	}
+	return result
+}
+
+func saveFile(reader io.Reader, path string) (*extract.Report, error) {
+	report := &extract.Report{}
 	file, err := os.Create(path)
 	if err != nil {
-		return 0, err
+		return report, err
 	}
 	written, err := io.Copy(file, reader)
+	report.Bytes = written
 	if err != nil {
-		return 0, err
+		_ = file.Close()
+		return report, err
+	}
+	report.Files = append(report.Files, extract.File{Path: filepath.Base(path), Bytes: written})
+	return report, file.Close()
+}
+
+// DownloadOption configures optional behaviors of the DownloadHandler.
+type DownloadOption func(o *downloadOptions)
+
+type downloadOptions struct {
+	guard   *symlink.Guard
+	hidden  *hidden.Matcher
+	storage storage.Storage
+}
+
+// WithDownloadGuard makes the DownloadHandler refuse the paths reached
+// through symlinks not allowed by the guard, and omit such symlinks
+// from the archives.
+func WithDownloadGuard(guard *symlink.Guard) DownloadOption {
+	return func(o *downloadOptions) {
+		o.guard = guard
+	}
+}
+
+// WithDownloadHidden makes the DownloadHandler refuse the hidden
+// paths, and omit the hidden files from the archives.
+func WithDownloadHidden(matcher *hidden.Matcher) DownloadOption {
+	return func(o *downloadOptions) {
+		o.hidden = matcher
 	}
-	return written, nil
 }
 
-func DownloadHandler(logger *logrus.Logger, root string) http.HandlerFunc {
+// WithDownloadStorage makes the DownloadHandler read the files from
+// the storage, instead of the local directory of the document root.
+func WithDownloadStorage(s storage.Storage) DownloadOption {
+	return func(o *downloadOptions) {
+		o.storage = s
+	}
+}
+
+func DownloadHandler(logger *logrus.Logger, root string, opts ...DownloadOption) http.HandlerFunc {
+	o := &downloadOptions{storage: storage.NewLocal(root)}
+	for _, opt := range opts {
+		opt(o)
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Header.Get("Accept") != ContentTypeTarGzip {
 			http.NotFound(w, r)
 			return
 		}
-		downloadRelativePath := r.Header.Get("GoServe-Download-Path")
+		downloadRelativePath := downloadPath(r)
 		downloadAbsolutePath := filepath.Join(root, downloadRelativePath)
 		if err := pathutil.PathInRoot(root, downloadAbsolutePath); err != nil {
 			logger.WithError(err).Error("download path violation try")
 			reply(w, http.StatusBadRequest, err.Error())
 			return
 		}
-		writtenBytes, err := archive.CreateTARGZ(w, downloadAbsolutePath)
+		if o.hidden.Hidden(downloadRelativePath) {
+			http.NotFound(w, r)
+			return
+		}
+		if o.guard != nil {
+			if err := o.guard.Check(downloadAbsolutePath); err != nil {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		writtenBytes, err := pack.TARGZ(w, o.storage, filepath.ToSlash(downloadRelativePath), o.filter(root))
 		if err != nil {
 			logger.WithError(err).Error("fail writing tar.gz to wire")
 			return
@@ -116,6 +467,36 @@ func DownloadHandler(logger *logrus.Logger, root string) http.HandlerFunc {
 	}
 }
 
+// filter excludes from the archives the hidden files and the
+// symlinks not allowed by the guard.
+func (o *downloadOptions) filter(root string) pack.Filter {
+	return func(name string, info os.FileInfo) bool {
+		if o.hidden.Hidden(name) {
+			return false
+		}
+		if o.guard == nil || info.Mode()&os.ModeSymlink == 0 {
+			return true
+		}
+		return o.guard.Permits(diskPath(root, name))
+	}
+}
+
+// downloadPath returns the requested download path. The header takes
+// precedence over the "path" query parameter, which allows sharing
+// download links.
+func downloadPath(r *http.Request) string {
+	if p := r.Header.Get(DownloadPathHeader); p != "" {
+		return p
+	}
+	return r.URL.Query().Get("path")
+}
+
+func replyJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", ContentTypeJSON)
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
 func reply(w http.ResponseWriter, statusCode int, message string) {
 	w.WriteHeader(statusCode)
 	_, _ = w.Write([]byte(message))
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+
+	"go.eloylp.dev/kit/http/middleware"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/headers"
+)
+
+// headerRules applies the rules to the headers of every response, right
+// before they are written, so they can override the ones set by handlers.
+// Rules match the requested path, before the prefix of the document root
+// is stripped by the router. Responses without a body, whose handlers
+// write nothing, get the rules applied too.
+func headerRules(rules *headers.Rules) middleware.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hw := &headersWriter{ResponseWriter: w, rules: rules, path: requestPath(r)}
+			h.ServeHTTP(hw, r)
+			if !hw.wroteHeader {
+				hw.WriteHeader(http.StatusOK)
+			}
+		})
+	}
+}
+
+// requestPath returns the path originally requested by the client,
+// as handlers may rewrite the one in the URL.
+func requestPath(r *http.Request) string {
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		return u.Path
+	}
+	return r.URL.Path
+}
+
+type headersWriter struct {
+	http.ResponseWriter
+	rules       *headers.Rules
+	path        string
+	wroteHeader bool
+}
+
+func (w *headersWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		w.rules.Apply(w.path, w.Header())
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *headersWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *headersWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func headerRulesFrom(rules config.HeaderRules) []headers.Rule {
+	result := make([]headers.Rule, 0, len(rules))
+	for _, r := range rules {
+		result = append(result, headers.Rule{Pattern: r.Pattern, Action: r.Action, Name: r.Name, Value: r.Value})
+	}
+	return result
+}
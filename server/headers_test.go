@@ -0,0 +1,37 @@
+package server //nolint:testpackage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/headers"
+)
+
+func headerRulesFor(t *testing.T, h http.Handler) http.Handler {
+	rules, err := headers.New([]headers.Rule{{Pattern: "/**", Action: headers.Set, Name: "X-Foo", Value: "bar"}})
+	require.NoError(t, err)
+	return headerRules(rules)(h)
+}
+
+func TestHeaderRulesApplyToEmptyResponses(t *testing.T) {
+	rec := httptest.NewRecorder()
+	headerRulesFor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).
+		ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/file.txt", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "bar", rec.Header().Get("X-Foo"))
+}
+
+func TestHeaderRulesKeepStreaming(t *testing.T) {
+	rec := httptest.NewRecorder()
+	headerRulesFor(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := w.(http.Flusher)
+		require.True(t, ok)
+		f.Flush()
+	})).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/static/events", nil))
+	assert.True(t, rec.Flushed)
+	assert.Equal(t, "bar", rec.Header().Get("X-Foo"))
+}
@@ -0,0 +1,140 @@
+package server
+
+import (
+	"bytes"
+	_ "embed" // The default index template.
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"go.eloylp.dev/go-serve/glob"
+)
+
+//go:embed templates/index.html
+var defaultIndexTemplate string
+
+// indexFuncs are available to the index templates, including
+// the ones provided by users.
+var indexFuncs = template.FuncMap{
+	"size": humanSize,
+}
+
+// indexTemplate parses the index template at path,
+// or the default one if the path is empty.
+func indexTemplate(path string) (*template.Template, error) {
+	if path == "" {
+		return template.Must(template.New("index").Funcs(indexFuncs).Parse(defaultIndexTemplate)), nil
+	}
+	t, err := template.New("index").Funcs(indexFuncs).ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("index template: %w", err)
+	}
+	return t.Lookup(filepath.Base(path)), nil
+}
+
+// listingDisabled compiles the glob patterns of the directories,
+// relative to the document root, whose listings are disabled.
+func listingDisabled(patterns []string) ([]*regexp.Regexp, error) {
+	result := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		re, err := glob.Compile(strings.Trim(strings.TrimSpace(p), "/"))
+		if err != nil {
+			return nil, fmt.Errorf("listing disabled: %w", err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// IndexPage is the data the index template is executed with.
+type IndexPage struct {
+	Path        string
+	Breadcrumbs []Breadcrumb
+	Entries     []IndexEntry
+	Sort        string
+	Order       string
+}
+
+// Breadcrumb is a link to one of the parent directories
+// of the listed one, or to itself.
+type Breadcrumb struct {
+	Name string
+	URL  string
+}
+
+// IndexEntry is a listed entry, with its URL relative to the listed
+// directory. Directory URLs end with a slash.
+type IndexEntry struct {
+	Entry
+	URL string
+}
+
+// SortURL returns the query that sorts the listing by the field, in
+// ascending order, or in descending order if it is already sorted by it.
+func (p *IndexPage) SortURL(field string) string {
+	order := "asc"
+	if p.Sort == field && p.Order == "asc" {
+		order = "desc"
+	}
+	return "?sort=" + url.QueryEscape(field) + "&order=" + order
+}
+
+func (l *listings) index(w http.ResponseWriter, dir string, entries []Entry, q *listingQuery) {
+	p := &IndexPage{
+		Path:        strings.TrimSuffix(dir, "/") + "/",
+		Breadcrumbs: breadcrumbs(l.prefix, dir),
+		Entries:     make([]IndexEntry, 0, len(entries)),
+		Sort:        q.sort,
+		Order:       "asc",
+	}
+	if q.desc {
+		p.Order = "desc"
+	}
+	for _, e := range entries {
+		name := e.Name
+		if e.Type == "dir" {
+			name += "/"
+		}
+		p.Entries = append(p.Entries, IndexEntry{Entry: e, URL: (&url.URL{Path: name}).String()})
+	}
+	var b bytes.Buffer
+	if err := l.template.Execute(&b, p); err != nil {
+		reply(w, http.StatusInternalServerError, "cannot render directory listing")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = b.WriteTo(w)
+}
+
+// breadcrumbs returns the links to the parents of the
+// directory, from the prefix to the directory itself.
+func breadcrumbs(prefix, dir string) []Breadcrumb {
+	current := strings.TrimSuffix(prefix, "/") + "/"
+	result := []Breadcrumb{{Name: "/", URL: current}}
+	for _, segment := range strings.Split(strings.Trim(dir, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		current += (&url.URL{Path: segment}).String() + "/"
+		result = append(result, Breadcrumb{Name: segment, URL: current})
+	}
+	return result
+}
+
+// humanSize formats a size in bytes with binary units.
+func humanSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,28 @@
+package server //nolint:testpackage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBreadcrumbs(t *testing.T) {
+	assert.Equal(t, []Breadcrumb{
+		{Name: "/", URL: "/static/"},
+		{Name: "notes", URL: "/static/notes/"},
+		{Name: "sub notes", URL: "/static/notes/sub%20notes/"},
+	}, breadcrumbs("/static", "/notes/sub notes"))
+	assert.Equal(t, []Breadcrumb{{Name: "/", URL: "/"}}, breadcrumbs("/", "/"))
+}
+
+func TestHumanSize(t *testing.T) {
+	assert.Equal(t, "512 B", humanSize(512))
+	assert.Equal(t, "1.5 KiB", humanSize(1536))
+	assert.Equal(t, "2.0 MiB", humanSize(2*1024*1024))
+}
+
+func TestIndexPageSortURL(t *testing.T) {
+	p := &IndexPage{Sort: "size", Order: "asc"}
+	assert.Equal(t, "?sort=size&order=desc", p.SortURL("size"))
+	assert.Equal(t, "?sort=name&order=asc", p.SortURL("name"))
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.eloylp.dev/kit/http/middleware"
+
+	"go.eloylp.dev/go-serve/limit"
+	"go.eloylp.dev/go-serve/metrics"
+)
+
+const concurrencyRetryAfter = time.Second
+
+// rateLimit rejects the requests that exceed the rate of their key.
+// Requests without a key are not limited.
+func rateLimit(l *limit.Rate, reason string, key func(r *http.Request) (string, bool)) middleware.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			k, ok := key(r)
+			if !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+			if allowed, wait := l.Allow(k); !allowed {
+				tooManyRequests(w, r, reason, wait)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// concurrencyLimit rejects the requests that would exceed the
+// maximum number of concurrent operations.
+func concurrencyLimit(c *limit.Concurrency, reason string) middleware.Middleware {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !c.TryAcquire() {
+				tooManyRequests(w, r, reason, concurrencyRetryAfter)
+				return
+			}
+			defer c.Release()
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+func ipKey(r *http.Request) (string, bool) {
+	return clientIP(r), true
+}
+
+func tooManyRequests(w http.ResponseWriter, r *http.Request, reason string, retryAfter time.Duration) {
+	if metrics.RateLimitRejections != nil {
+		metrics.RateLimitRejections.WithLabelValues(metrics.Labels(r.Context(), reason)...).Inc()
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	reply(w, http.StatusTooManyRequests, http.StatusText(http.StatusTooManyRequests))
+}
@@ -0,0 +1,347 @@
+package server
+
+import (
+	"html/template"
+	"mime"
+	"net/http"
+	"os"
+	stdpath "path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultListingPageSize = 100
+	maxListingPageSize     = 1000
+	maxListingDepth        = 10
+	maxListingEntries      = 10_000
+)
+
+// Entry describes a file in a directory listing. The child count is
+// only reported for directories, and the SHA-256 of regular files only
+// if it was already computed.
+type Entry struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	Type     string    `json:"type"`
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mtime"`
+	SHA256   string    `json:"sha256,omitempty"`
+	Children *int      `json:"children,omitempty"`
+}
+
+// Listing is a page of the entries of a directory. Truncated listings
+// stopped walking the subdirectories once enough entries were collected,
+// so their total only counts the collected ones.
+type Listing struct {
+	Path      string  `json:"path"`
+	Page      int     `json:"page"`
+	PerPage   int     `json:"per_page"`
+	Total     int     `json:"total"`
+	Truncated bool    `json:"truncated,omitempty"`
+	Entries   []Entry `json:"entries"`
+}
+
+// hashLookup returns the content hash of a file, if already known.
+type hashLookup func(name string, info os.FileInfo) (string, bool)
+
+// listings serves the directories under the prefix. Directories are
+// listed as JSON to the requests that accept it, and with the index
+// template otherwise, unless they have an index.html file or listings
+// are disabled for them. Any other request is passed to the next handler.
+// Listings are read through the document root file system, so hidden
+// files and forbidden symlinks are never listed.
+type listings struct {
+	fs       http.FileSystem
+	prefix   string
+	hashes   hashLookup
+	template *template.Template
+	disabled []*regexp.Regexp
+	next     http.Handler
+}
+
+func (l *listings) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := stdpath.Clean("/" + r.URL.Path)
+	f, err := l.fs.Open(name)
+	if err != nil {
+		l.next.ServeHTTP(w, r)
+		return
+	}
+	info, err := f.Stat()
+	_ = f.Close()
+	if err != nil || !info.IsDir() {
+		l.next.ServeHTTP(w, r)
+		return
+	}
+	asJSON := acceptsJSON(r)
+	if !asJSON && (!strings.HasSuffix(r.URL.Path, "/") || l.hasIndex(name)) {
+		l.next.ServeHTTP(w, r)
+		return
+	}
+	if l.isDisabled(name) {
+		replyListingError(w, asJSON, http.StatusForbidden, "directory listing is disabled")
+		return
+	}
+	q, err := listingQueryFrom(r)
+	if err != nil {
+		replyListingError(w, asJSON, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !asJSON {
+		q.depth = 1
+	}
+	limit := maxListingEntries
+	if q.page <= maxListingEntries/q.perPage {
+		limit = q.page * q.perPage
+	}
+	entries, truncated, err := listEntries(l.fs, l.hashes, name, q.depth, limit)
+	if err != nil {
+		replyListingError(w, asJSON, http.StatusInternalServerError, "cannot list directory")
+		return
+	}
+	sortEntries(entries, q.sort, q.desc)
+	if asJSON {
+		listing := page(name, entries, q.page, q.perPage)
+		listing.Truncated = truncated
+		replyJSON(w, http.StatusOK, listing)
+		return
+	}
+	l.index(w, name, entries, q)
+}
+
+func replyListingError(w http.ResponseWriter, asJSON bool, statusCode int, message string) {
+	if asJSON {
+		replyJSON(w, statusCode, map[string]string{"error": message})
+		return
+	}
+	reply(w, statusCode, message)
+}
+
+// hasIndex reports whether the directory has an index.html
+// file, which the file server serves instead of a listing.
+func (l *listings) hasIndex(dir string) bool {
+	return exists(l.fs, stdpath.Join(dir, "index.html"))
+}
+
+func (l *listings) isDisabled(dir string) bool {
+	dir = strings.Trim(dir, "/")
+	for _, re := range l.disabled {
+		if re.MatchString(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+// acceptsJSON reports whether JSON is the preferred media type
+// of the request, ignoring wildcards.
+func acceptsJSON(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if mediaType == ContentTypeJSON {
+			return true
+		}
+		if mediaType != "*/*" {
+			return false
+		}
+	}
+	return false
+}
+
+type listingQuery struct {
+	page    int
+	perPage int
+	depth   int
+	sort    string
+	desc    bool
+}
+
+func listingQueryFrom(r *http.Request) (*listingQuery, error) {
+	values := r.URL.Query()
+	q := &listingQuery{page: 1, perPage: defaultListingPageSize, depth: 1, sort: "name"}
+	ints := []struct {
+		name string
+		dst  *int
+		max  int
+	}{
+		{"page", &q.page, 0},
+		{"per_page", &q.perPage, maxListingPageSize},
+		{"depth", &q.depth, maxListingDepth},
+	}
+	for _, i := range ints {
+		v := values.Get(i.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || (i.max > 0 && n > i.max) {
+			return nil, &queryError{param: i.name, value: v}
+		}
+		*i.dst = n
+	}
+	if s := values.Get("sort"); s != "" {
+		switch s {
+		case "name", "size", "mtime", "type":
+			q.sort = s
+		default:
+			return nil, &queryError{param: "sort", value: s}
+		}
+	}
+	switch o := values.Get("order"); o {
+	case "", "asc":
+	case "desc":
+		q.desc = true
+	default:
+		return nil, &queryError{param: "order", value: o}
+	}
+	return q, nil
+}
+
+type queryError struct {
+	param string
+	value string
+}
+
+func (e *queryError) Error() string {
+	return "invalid value " + strconv.Quote(e.value) + " for parameter " + e.param
+}
+
+// listEntries lists the directory, and its subdirectories up to the
+// provided depth, as a flat list of entries. The directory is always
+// listed completely, but its subdirectories are only walked until the
+// limit of entries is reached, which is reported as a truncated listing.
+// Unreadable subdirectories are listed without their child count.
+func listEntries(fs http.FileSystem, hashes hashLookup, dir string, depth, limit int) ([]Entry, bool, error) {
+	infos, err := readDir(fs, dir)
+	if err != nil {
+		return nil, false, err
+	}
+	w := &listingWalk{fs: fs, hashes: hashes, root: dir, limit: limit, entries: make([]Entry, 0, len(infos))}
+	w.walk(dir, infos, depth)
+	return w.entries, w.truncated, nil
+}
+
+type listingWalk struct {
+	fs        http.FileSystem
+	hashes    hashLookup
+	root      string
+	limit     int
+	entries   []Entry
+	truncated bool
+}
+
+// walk collects the entries of the already read directory, reading
+// every subdirectory once, for both its child count and its entries.
+func (w *listingWalk) walk(dir string, infos []os.FileInfo, depth int) {
+	for _, info := range infos {
+		if dir != w.root && len(w.entries) >= w.limit {
+			w.truncated = true
+			return
+		}
+		e := Entry{
+			Name:    info.Name(),
+			Path:    stdpath.Join(dir, info.Name()),
+			Type:    entryType(info),
+			Size:    info.Size(),
+			ModTime: info.ModTime().UTC(),
+		}
+		if w.hashes != nil && info.Mode().IsRegular() {
+			e.SHA256, _ = w.hashes(e.Path, info)
+		}
+		if !info.IsDir() {
+			w.entries = append(w.entries, e)
+			continue
+		}
+		children, err := readDir(w.fs, e.Path)
+		if err == nil {
+			count := len(children)
+			e.Children = &count
+		}
+		w.entries = append(w.entries, e)
+		if err != nil || depth <= 1 {
+			continue
+		}
+		if len(w.entries) >= w.limit {
+			w.truncated = true
+			continue
+		}
+		w.walk(e.Path, children, depth-1)
+	}
+}
+
+func readDir(fs http.FileSystem, dir string) ([]os.FileInfo, error) {
+	f, err := fs.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdir(-1)
+}
+
+func entryType(info os.FileInfo) string {
+	switch {
+	case info.IsDir():
+		return "dir"
+	case info.Mode()&os.ModeSymlink != 0:
+		return "symlink"
+	case info.Mode().IsRegular():
+		return "file"
+	default:
+		return "other"
+	}
+}
+
+func sortEntries(entries []Entry, field string, desc bool) {
+	less := func(a, b *Entry) bool {
+		switch field {
+		case "size":
+			if a.Size != b.Size {
+				return a.Size < b.Size
+			}
+		case "mtime":
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.Before(b.ModTime)
+			}
+		case "type":
+			if a.Type != b.Type {
+				return a.Type < b.Type
+			}
+		}
+		return a.Path < b.Path
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if desc {
+			return less(&entries[j], &entries[i])
+		}
+		return less(&entries[i], &entries[j])
+	})
+}
+
+// page returns the page of the entries, which is empty past the end.
+// Page numbers are compared before multiplying, so they cannot overflow.
+func page(dir string, entries []Entry, number, size int) *Listing {
+	start := len(entries)
+	if number-1 <= len(entries)/size {
+		start = (number - 1) * size
+	}
+	if start > len(entries) {
+		start = len(entries)
+	}
+	end := start + size
+	if end > len(entries) {
+		end = len(entries)
+	}
+	return &Listing{
+		Path:    dir,
+		Page:    number,
+		PerPage: size,
+		Total:   len(entries),
+		Entries: entries[start:end],
+	}
+}
@@ -0,0 +1,54 @@
+package server //nolint:testpackage
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unreadableFS fails to open one of the directories of the file system.
+type unreadableFS struct {
+	http.FileSystem
+	dir string
+}
+
+func (fs unreadableFS) Open(name string) (http.File, error) {
+	if name == fs.dir {
+		return nil, os.ErrPermission
+	}
+	return fs.FileSystem.Open(name)
+}
+
+func listingTree(t *testing.T) http.FileSystem {
+	root := t.TempDir()
+	for _, name := range []string{"a/x.txt", "b/y.txt"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(name), 0600))
+	}
+	return http.Dir(root)
+}
+
+func TestListEntriesSkipsUnreadableSubdirectories(t *testing.T) {
+	entries, truncated, err := listEntries(unreadableFS{FileSystem: listingTree(t), dir: "/b"}, nil, "/", 2, maxListingEntries)
+	require.NoError(t, err)
+	assert.False(t, truncated)
+	sortEntries(entries, "name", false)
+	paths := make([]string, 0, len(entries))
+	for _, e := range entries {
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{"/a", "/a/x.txt", "/b"}, paths)
+	assert.Nil(t, entries[2].Children)
+}
+
+func TestListEntriesStopsWalkingAtTheLimit(t *testing.T) {
+	entries, truncated, err := listEntries(listingTree(t), nil, "/", 2, 1)
+	require.NoError(t, err)
+	assert.True(t, truncated)
+	assert.Len(t, entries, 2, "the directory itself is always listed completely")
+}
@@ -0,0 +1,114 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	stdpath "path"
+	"path/filepath"
+	"strings"
+
+	"go.eloylp.dev/kit/http/middleware"
+
+	"go.eloylp.dev/go-serve/archivefs"
+	"go.eloylp.dev/go-serve/config"
+)
+
+// forMount returns a copy of the authenticators, with the basic auth
+// checkers of the authorizations of the mount, which default to the
+// ones of the settings.
+func (a *authenticators) forMount(cfg *config.Settings, m *config.Mount) *authenticators {
+	mounted := *a
+	mounted.read, mounted.write = nil, nil
+	if read := mountAuthorizations(m.ReadAuthorizations, cfg.ReadAuthorizations); len(read) > 0 {
+		mounted.read = middleware.AuthChecker(middleware.NewAuthConfig().
+			WithAuth(middleware.Authorization(read)).
+			WithMethod(http.MethodGet).
+			WithPathRegex(".*"))
+	}
+	if write := mountAuthorizations(m.WriteAuthorizations, cfg.WriteAuthorizations); len(write) > 0 {
+		mounted.write = middleware.AuthChecker(middleware.NewAuthConfig().
+			WithAuth(middleware.Authorization(write)).
+			WithMethod(http.MethodPost).
+			WithPathRegex(fmt.Sprintf("^%s$", m.Prefix)))
+	}
+	return &mounted
+}
+
+// mountAuthorizations returns the authorizations of the mount,
+// or the inherited ones if it has none.
+func mountAuthorizations(own, inherited config.Authorization) config.Authorization {
+	if len(own) > 0 {
+		return own
+	}
+	return inherited
+}
+
+// mountFiles authenticates the requests to the files of a mount. Token
+// scopes are checked against the public paths, prefix included, so they
+// never grant access to the same paths of the document root.
+func (a *authenticators) mountFiles(prefix string) *authentication {
+	files := a.files(prefix)
+	files.target = files.publicPath
+	return files
+}
+
+// mountUpload authenticates the uploads to a mount, whose
+// deploy paths are checked prefixed by the mount one.
+func (a *authenticators) mountUpload(prefix string) *authentication {
+	upload := a.upload()
+	upload.target = func(r *http.Request) string {
+		return prefix + stdpath.Join("/", r.Header.Get(DeployPathHeader))
+	}
+	return upload
+}
+
+// validateMounts checks that every mount has an existing directory, or
+// a supported archive if read only, and a prefix that does not overlap
+// with the other ones, the document root one included, as the router
+// could not tell them apart. Writable mounts must require authentication,
+// by write authorizations or API tokens.
+func validateMounts(cfg *config.Settings) error {
+	prefixes := []string{cfg.Prefix}
+	for _, m := range cfg.Mounts {
+		if !strings.HasPrefix(m.Prefix, "/") || strings.HasSuffix(m.Prefix, "/") {
+			return fmt.Errorf("mount %s prefix %q must start and not end with /", m.Name, m.Prefix)
+		}
+		for _, p := range prefixes {
+			if overlaps(p, m.Prefix) {
+				return fmt.Errorf("mount %s prefix %s overlaps with %s", m.Name, m.Prefix, p)
+			}
+		}
+		prefixes = append(prefixes, m.Prefix)
+		dir, err := filepath.Abs(m.Dir)
+		if err != nil {
+			return err
+		}
+		switch info, err := os.Stat(dir); {
+		case err == nil && info.IsDir():
+		case isArchive(dir) && m.ReadOnly:
+		case isArchive(dir):
+			return fmt.Errorf("mount %s of archive %s must be read only", m.Name, m.Dir)
+		default:
+			return fmt.Errorf("mount %s directory %s is not accessible", m.Name, m.Dir)
+		}
+		if !m.ReadOnly && cfg.TokensFile == "" && len(mountAuthorizations(m.WriteAuthorizations, cfg.WriteAuthorizations)) == 0 {
+			return fmt.Errorf("writable mount %s requires write authorizations or API tokens", m.Name)
+		}
+		if _, err := listingDisabled(m.ListingDisabled); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func overlaps(a, b string) bool {
+	return a == b || strings.HasPrefix(a, b+"/") || strings.HasPrefix(b, a+"/") || a == "" || b == ""
+}
+
+// isArchive reports whether the path is a regular
+// file with the extension of a supported archive.
+func isArchive(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && archivefs.Supported(path)
+}
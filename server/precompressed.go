@@ -0,0 +1,148 @@
+package server
+
+import (
+	"io"
+	"mime"
+	"net/http"
+	stdpath "path"
+	"strconv"
+	"strings"
+
+	"go.eloylp.dev/go-serve/digest"
+)
+
+// sidecars are the precompressed variants of the files, by
+// their content encoding, in order of preference.
+var sidecars = []struct {
+	encoding  string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// precompressed serves the precompressed sidecars of the requested
+// files, like app.js.br or app.js.gz for app.js, to the clients that
+// accept their encodings. Variants keep the content type of the original
+// file, while ranges and conditional requests apply to the variant.
+// Variants have their own ETags, if digests are provided. Any other
+// request is passed to the next handler.
+func precompressed(fs http.FileSystem, root string, digests *digest.Cache, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := stdpath.Clean("/" + r.URL.Path)
+		if strings.HasSuffix(r.URL.Path, "/") || strings.HasSuffix(name, "/index.html") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		original, err := fs.Open(name)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer original.Close()
+		info, err := original.Stat()
+		if err != nil || info.IsDir() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+		var variant http.File
+		var encoding, extension string
+		var best float64
+		found := false
+		for _, s := range sidecars {
+			f, err := fs.Open(name + s.extension)
+			if err != nil {
+				continue
+			}
+			fi, err := f.Stat()
+			if err != nil || !fi.Mode().IsRegular() {
+				_ = f.Close()
+				continue
+			}
+			found = true
+			if q := accepted.quality(s.encoding); q > best {
+				if variant != nil {
+					_ = variant.Close()
+				}
+				variant, encoding, extension, best = f, s.encoding, s.extension, q
+				continue
+			}
+			_ = f.Close()
+		}
+		if found {
+			addVary(w.Header(), "Accept-Encoding")
+		}
+		if variant == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer variant.Close()
+		variantInfo, err := variant.Stat()
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		contentType, err := originalContentType(name, original)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := setETag(w, digests, diskPath(root, name+extension), variantInfo, variant); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Encoding", encoding)
+		http.ServeContent(w, r, name, variantInfo.ModTime(), variant)
+	})
+}
+
+// originalContentType returns the content type of the original file,
+// by its extension or, if unknown, by sniffing its content.
+func originalContentType(name string, f io.Reader) (string, error) {
+	if ctype := mime.TypeByExtension(stdpath.Ext(name)); ctype != "" {
+		return ctype, nil
+	}
+	var buf [512]byte
+	n, err := io.ReadFull(f, buf[:])
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", err
+	}
+	return http.DetectContentType(buf[:n]), nil
+}
+
+// encodings are the qualities of the accepted content encodings.
+type encodings map[string]float64
+
+// acceptedEncodings parses an Accept-Encoding header.
+func acceptedEncodings(header string) encodings {
+	result := encodings{}
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		coding := strings.ToLower(strings.TrimSpace(fields[0]))
+		if coding == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		result[coding] = q
+	}
+	return result
+}
+
+// quality returns the quality of the coding, falling back
+// to the wildcard, or zero if it is not accepted.
+func (e encodings) quality(coding string) float64 {
+	if q, ok := e[coding]; ok {
+		return q
+	}
+	return e["*"]
+}
@@ -0,0 +1,22 @@
+package server //nolint:testpackage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAcceptedEncodings(t *testing.T) {
+	e := acceptedEncodings("gzip;q=0.8, BR, identity;q=0")
+	assert.Equal(t, 1.0, e.quality("br"))
+	assert.Equal(t, 0.8, e.quality("gzip"))
+	assert.Equal(t, 0.0, e.quality("zstd"))
+	assert.Equal(t, 0.0, e.quality("identity"))
+}
+
+func TestAcceptedEncodingsWildcard(t *testing.T) {
+	e := acceptedEncodings("*;q=0.5, br;q=0")
+	assert.Equal(t, 0.0, e.quality("br"))
+	assert.Equal(t, 0.5, e.quality("gzip"))
+	assert.Equal(t, 0.0, acceptedEncodings("").quality("gzip"))
+}
@@ -0,0 +1,41 @@
+package server
+
+import "net/http"
+
+// statusRecorder keeps track of the status code written by
+// the wrapped handlers, and whether writing the body failed.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	failed bool
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	if err != nil {
+		s.failed = true
+	}
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// succeeded reports whether a successful response was written
+// completely. Handlers not writing anything reply with 200 OK.
+func (s *statusRecorder) succeeded() bool {
+	return !s.failed && (s.status == 0 || s.status >= 200 && s.status < 300)
+}
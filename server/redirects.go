@@ -0,0 +1,135 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/metrics"
+	"go.eloylp.dev/go-serve/redirect"
+	"go.eloylp.dev/go-serve/storage"
+)
+
+// redirectsFile is the name of the rules file at the document root.
+const redirectsFile = "_redirects"
+
+// redirects applies the configured rules, followed by the ones of the
+// _redirects file of the document root, if enabled, before the requests
+// reach the file server. Rules match the paths relative to the prefix.
+// Redirect targets are relative to the prefix too, unless they are
+// absolute URLs, while rewrites change the path the file server serves,
+// which must be allowed by the scopes or signature of the request too.
+type redirects struct {
+	logger *logrus.Logger
+	prefix string
+	rules  *redirect.Table
+	store  storage.Storage
+	mu     sync.Mutex
+	cached *redirect.Table
+	mod    time.Time
+	next   http.Handler
+}
+
+func (d *redirects) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rule, target, ok := d.rules.Match(r.URL.Path)
+	if !ok {
+		rule, target, ok = d.fileRules().Match(r.URL.Path)
+	}
+	if !ok {
+		d.next.ServeHTTP(w, r)
+		return
+	}
+	redirectHit(r, rule)
+	if rule.Status == redirect.Rewrite {
+		u, err := url.Parse(target)
+		if err != nil {
+			reply(w, http.StatusInternalServerError, "invalid rewrite target")
+			return
+		}
+		if !targetAllowed(r, u.Path) {
+			reply(w, http.StatusForbidden, "rewrite target not allowed")
+			return
+		}
+		r.URL.Path = u.Path
+		if u.RawQuery != "" {
+			r.URL.RawQuery = u.RawQuery
+		}
+		d.next.ServeHTTP(w, r)
+		return
+	}
+	if strings.HasPrefix(target, "/") {
+		target = d.prefix + target
+	}
+	if r.URL.RawQuery != "" && !strings.Contains(target, "?") {
+		target += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, target, rule.Status)
+}
+
+// fileRules returns the rules of the _redirects file, which are
+// parsed again once it is modified. Invalid files are ignored.
+func (d *redirects) fileRules() *redirect.Table {
+	if d.store == nil {
+		return nil
+	}
+	info, err := d.store.Stat(redirectsFile)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.cached != nil && d.mod.Equal(info.ModTime()) {
+		return d.cached
+	}
+	data, err := d.read()
+	if err != nil {
+		d.logger.WithError(err).Warn("cannot read redirects file")
+		return nil
+	}
+	rules, err := redirect.Parse(string(data))
+	if err == nil {
+		d.cached, err = redirect.New(rules)
+	}
+	if err != nil {
+		d.logger.WithError(err).Warnf("ignoring invalid %s file", redirectsFile)
+		d.cached = &redirect.Table{}
+	}
+	d.mod = info.ModTime()
+	return d.cached
+}
+
+func (d *redirects) read() ([]byte, error) {
+	f, err := d.store.Open(redirectsFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
+
+func configureRedirects(cfg *config.Settings, logger *logrus.Logger, store storage.Storage, next http.Handler) http.Handler {
+	d := &redirects{logger: logger, prefix: cfg.Prefix, next: next}
+	rules, _ := redirect.Parse(cfg.Redirects) // Already validated by New.
+	d.rules, _ = redirect.New(rules)
+	if len(rules) > 0 {
+		logger.Infof("configuring %d redirect rules", len(rules))
+	}
+	if cfg.RedirectsFile {
+		d.store = store
+		logger.Infof("configuring redirect rules from the %s file", redirectsFile)
+	}
+	return d
+}
+
+func redirectHit(r *http.Request, rule redirect.Rule) {
+	if metrics.RedirectHits != nil {
+		metrics.RedirectHits.WithLabelValues(metrics.Labels(r.Context(), rule.From, strconv.Itoa(rule.Status))...).Inc()
+	}
+}
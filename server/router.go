@@ -3,6 +3,8 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/prometheus/client_golang/prometheus"
@@ -10,49 +12,353 @@ import (
 	"github.com/sirupsen/logrus"
 	"go.eloylp.dev/kit/http/middleware"
 
+	"go.eloylp.dev/go-serve/archivefs"
+	"go.eloylp.dev/go-serve/audit"
+	"go.eloylp.dev/go-serve/auth"
+	"go.eloylp.dev/go-serve/compression"
 	"go.eloylp.dev/go-serve/config"
-	"go.eloylp.dev/go-serve/metrics"
+	"go.eloylp.dev/go-serve/digest"
+	"go.eloylp.dev/go-serve/extract"
+	"go.eloylp.dev/go-serve/headers"
+	"go.eloylp.dev/go-serve/hidden"
+	"go.eloylp.dev/go-serve/limit"
+	"go.eloylp.dev/go-serve/scan"
+	"go.eloylp.dev/go-serve/storage"
+	"go.eloylp.dev/go-serve/symlink"
+	"go.eloylp.dev/go-serve/upload"
 )
 
-func router(cfg *config.Settings, logger *logrus.Logger, docRoot string, info Info) http.Handler {
+// digestCacheSize is the maximum number of files
+// whose content hashes are kept for their ETags.
+const digestCacheSize = 100_000
+
+// archiveCacheSize is the maximum number of archives whose indexes
+// are kept open. Their spooled tarballs may take up to this many times
+// the archives max size of disk, as documented in the README.
+const archiveCacheSize = 64
+
+func router(cfg *config.Settings, logger *logrus.Logger, docRoot string, info Info, auditSink audit.Sink, host string, reg prometheus.Registerer, sh *shared) http.Handler {
 	r := httprouter.New()
 	var userMiddlewares []middleware.Middleware
+	if len(cfg.TrustedProxies) > 0 {
+		logger.Info("configuring trusted proxies in server")
+		userMiddlewares = append(userMiddlewares, realIP(cfg.TrustedProxies))
+	}
 	if cfg.MetricsEnabled {
-		userMiddlewares = append(userMiddlewares, configureMetrics(cfg)...)
+		userMiddlewares = append(userMiddlewares, configureMetrics(cfg, reg)...)
 	}
 	if cfg.MetricsEnabled && cfg.MetricsListenAddr == "" {
-		r.Handler(http.MethodGet, cfg.MetricsPath, promhttp.Handler())
+		r.Handler(http.MethodGet, cfg.MetricsPath, middleware.For(promhttp.Handler(), accessMiddlewares(cfg, cfg.Access.Metrics)...))
 		logger.Infof("configuring metrics at %s endpoint", cfg.MetricsPath)
 	}
+	store := cfg.Storage
+	if store == nil {
+		store = storage.NewLocal(docRoot)
+	} else {
+		logger.Info("configuring custom storage for the document root")
+	}
+	var guard *symlink.Guard
+	if local, ok := store.(*storage.Local); ok {
+		// Local storages are read at their own root on disk,
+		// which is where their symlinks must be checked.
+		if root, err := filepath.Abs(local.Root()); err == nil {
+			docRoot = root
+		}
+		guard = symlink.NewGuard(docRoot, symlink.Policy(cfg.Symlinks))
+		logger.Infof("configuring symlinks policy as %s", cfg.Symlinks)
+	}
+	hiddenPatterns := cfg.HiddenFiles
+	if cfg.RedirectsFile {
+		hiddenPatterns = append(append([]string{}, hiddenPatterns...), "/"+redirectsFile)
+	}
+	hiddenFiles, _ := hidden.New(hiddenPatterns) // Already validated by New.
+	fs := newDocRootFS(docRoot, store, guard, hiddenFiles)
+	var digests *digest.Cache
+	if cfg.ETags {
+		logger.Info("configuring content hash ETags in file server")
+		digests, _ = digest.NewCache(digestCacheSize) // Only fails with non positive sizes.
+	}
+	errPages := configureErrorPages(cfg, logger, fs)
+	r.NotFound = middleware.For(http.NotFoundHandler(), errPages.middleware())
 	userMiddlewares = append(userMiddlewares,
 		middleware.RequestLogger(logger),
 		middleware.ServerHeader(fmt.Sprintf("go-serve %s", Version)),
 	)
-	if len(cfg.ReadAuthorizations) > 0 {
-		logger.Info("configuring read authorizations in server")
-		authReadCfg := readAuthConfig(cfg)
-		userMiddlewares = append(userMiddlewares, middleware.AuthChecker(authReadCfg))
+	if len(cfg.HeaderRules) > 0 {
+		logger.Infof("configuring %d response header rules", len(cfg.HeaderRules))
+		rules, _ := headers.New(headerRulesFrom(cfg.HeaderRules)) // Already validated by New.
+		userMiddlewares = append(userMiddlewares, headerRules(rules))
 	}
-	if len(cfg.WriteAuthorizations) > 0 {
-		logger.Info("configuring write authorizations in server")
-		authWriteCfg := writeAuthConfig(cfg)
-		userMiddlewares = append(userMiddlewares, middleware.AuthChecker(authWriteCfg))
+	if cfg.Compression {
+		userMiddlewares = append(userMiddlewares, configureCompression(cfg, logger).middleware())
 	}
-	r.Handler(http.MethodGet, "/status", StatusHandler(info))
+	userMiddlewares = append(userMiddlewares, errPages.middleware())
+	if sh.ipRate != nil {
+		userMiddlewares = append(userMiddlewares, rateLimit(sh.ipRate, "ip", ipKey))
+	}
+	authn := sh.authn.forHost(host, cfg, logger)
+	r.Handler(http.MethodGet, "/status", middleware.For(StatusHandler(info), accessMiddlewares(cfg, cfg.Access.Status)...))
 	if cfg.DownloadEndpoint != "" {
-		r.Handler(http.MethodGet, cfg.DownloadEndpoint, middleware.For(DownloadHandler(logger, cfg.DocRoot), userMiddlewares...))
+		downloadCORS := withCORS(withIPFilter(userMiddlewares, cfg.Access.Download), cfg.CORS.Download, http.MethodGet)
+		if cfg.CORS.Download.Enabled() {
+			logger.Infof("configuring CORS for %v origins at %s endpoint", cfg.CORS.Download.AllowOrigins, cfg.DownloadEndpoint)
+			r.Handler(http.MethodOptions, cfg.DownloadEndpoint, middleware.For(optionsHandler(http.MethodGet), downloadCORS...))
+		}
+		downloadMiddlewares := withAuth(downloadCORS, authn.download())
+		if sh.downloads != nil {
+			downloadMiddlewares = chain(downloadMiddlewares, concurrencyLimit(sh.downloads, "downloads"))
+		}
+		r.Handler(http.MethodGet, cfg.DownloadEndpoint, middleware.For(DownloadHandler(logger, docRoot, WithDownloadGuard(guard), WithDownloadHidden(hiddenFiles), WithDownloadStorage(store)), downloadMiddlewares...))
 		logger.Infof("configuring downloads at %s endpoint", cfg.DownloadEndpoint)
 	}
+	uploadCORS := withCORS(withIPFilter(userMiddlewares, cfg.Access.Upload), cfg.CORS.Upload, http.MethodPost)
+	var uploadLimits []middleware.Middleware
+	if sh.uploads != nil {
+		uploadLimits = append(uploadLimits, concurrencyLimit(sh.uploads, "uploads"))
+	}
 	if cfg.UploadEndpoint != "" {
-		r.Handler(http.MethodPost, cfg.UploadEndpoint, middleware.For(UploadHandler(logger, cfg.DocRoot), userMiddlewares...))
+		if cfg.CORS.Upload.Enabled() {
+			logger.Infof("configuring CORS for %v origins at %s endpoint", cfg.CORS.Upload.AllowOrigins, cfg.UploadEndpoint)
+			r.Handler(http.MethodOptions, cfg.UploadEndpoint, middleware.For(optionsHandler(http.MethodPost), uploadCORS...))
+		}
+		uploadOpts := configureUploads(cfg, logger, store, guard, digests, auditSink)
+		r.Handler(http.MethodPost, cfg.UploadEndpoint, middleware.For(UploadHandler(logger, docRoot, uploadOpts...), chain(withAuth(uploadCORS, authn.upload()), uploadLimits...)...))
 		logger.Infof("configuring uploads at %s endpoint", cfg.UploadEndpoint)
 	}
-	fileHandler := http.FileServer(http.Dir(docRoot))
-	r.GET(cfg.Prefix+"/*filepath", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
+	filesCORS := withCORS(withIPFilter(userMiddlewares, cfg.Access.Files), cfg.CORS.Files, http.MethodGet, http.MethodHead)
+	if cfg.CORS.Files.Enabled() {
+		logger.Infof("configuring CORS for %v origins at %s prefix", cfg.CORS.Files.AllowOrigins, cfg.Prefix)
+		r.Handler(http.MethodOptions, cfg.Prefix+"/*filepath", middleware.For(optionsHandler(http.MethodGet, http.MethodHead), filesCORS...))
+	}
+	if cfg.Precompressed {
+		logger.Info("configuring precompressed sidecars in file server")
+	}
+	if cfg.ListingTemplate != "" {
+		logger.Infof("configuring directory listings template from %s", cfg.ListingTemplate)
+	}
+	fileHandler := fileServer(cfg, fs, docRoot, cfg.Prefix, digests, cfg.ListingDisabled)
+	if len(cfg.SPAPrefixes) > 0 {
+		logger.Infof("configuring single page application fallback for %v", cfg.SPAPrefixes)
+		fileHandler = spaHandler(fs, docRoot, digests, cfg.SPAPrefixes, fileHandler)
+	}
+	archiveCache := configureArchiveCache(cfg)
+	if cfg.Archives {
+		fileHandler = configureArchives(cfg, logger, fs, docRoot, archiveCache, hiddenFiles, fileHandler)
+	}
+	if cfg.Redirects != "" || cfg.RedirectsFile {
+		fileHandler = configureRedirects(cfg, logger, store, fileHandler)
+	}
+	serveFiles(r, cfg.Prefix, fileHandler, withAuth(filesCORS, authn.files(cfg.Prefix)))
+	for _, m := range cfg.Mounts {
+		logger.Infof("configuring mount of %s at %s", m.Dir, m.Prefix)
+		mountRoot, _ := filepath.Abs(m.Dir) // Already validated by New.
+		mountStore := storage.NewLocal(mountRoot)
+		mountGuard := symlink.NewGuard(mountRoot, symlink.Policy(cfg.Symlinks))
+		mountAuthn := authn.forMount(cfg, m)
+		if cfg.CORS.Files.Enabled() {
+			r.Handler(http.MethodOptions, m.Prefix+"/*filepath", middleware.For(optionsHandler(http.MethodGet, http.MethodHead), filesCORS...))
+		}
+		if isArchive(mountRoot) {
+			serveFiles(r, m.Prefix, archiveMount(cfg, logger, m, mountRoot, archiveCache, hiddenFiles), withAuth(filesCORS, mountAuthn.mountFiles(m.Prefix)))
+			continue
+		}
+		mountFS := newDocRootFS(mountRoot, mountStore, mountGuard, hiddenFiles)
+		serveFiles(r, m.Prefix, fileServer(cfg, mountFS, mountRoot, m.Prefix, digests, m.ListingDisabled), withAuth(filesCORS, mountAuthn.mountFiles(m.Prefix)))
+		if m.ReadOnly {
+			continue
+		}
+		if cfg.CORS.Upload.Enabled() {
+			r.Handler(http.MethodOptions, m.Prefix, middleware.For(optionsHandler(http.MethodPost), uploadCORS...))
+		}
+		uploadOpts := configureUploads(cfg, logger, mountStore, mountGuard, digests, auditSink)
+		r.Handler(http.MethodPost, m.Prefix, middleware.For(UploadHandler(logger, mountRoot, uploadOpts...), chain(withAuth(uploadCORS, mountAuthn.mountUpload(m.Prefix)), uploadLimits...)...))
+		logger.Infof("configuring uploads at %s mount", m.Prefix)
+	}
+	return r
+}
+
+// fileServer serves the files of the file system and the listings of
+// its directories, as configured by the settings.
+func fileServer(cfg *config.Settings, fs http.FileSystem, root, prefix string, digests *digest.Cache, disabled []string) http.Handler {
+	staticHandler := http.FileServer(fs)
+	if digests != nil {
+		staticHandler = etags(fs, root, digests, staticHandler)
+	}
+	if cfg.Precompressed {
+		staticHandler = precompressed(fs, root, digests, staticHandler)
+	}
+	staticHandler = recordModTime(fs, staticHandler)
+	dirHandler := &listings{fs: fs, prefix: prefix, hashes: digestLookup(root, digests), next: staticHandler}
+	dirHandler.template, _ = indexTemplate(cfg.ListingTemplate) // Already validated by New.
+	dirHandler.disabled, _ = listingDisabled(disabled)
+	return dirHandler
+}
+
+// serveFiles registers the route of the files under the prefix, whose
+// handler and middlewares see the paths relative to the prefix.
+func serveFiles(r *httprouter.Router, prefix string, h http.Handler, middlewares []middleware.Middleware) {
+	r.GET(prefix+"/*filepath", func(w http.ResponseWriter, r *http.Request, p httprouter.Params) {
 		r.URL.Path = p.ByName("filepath")
-		middleware.For(fileHandler, userMiddlewares...).ServeHTTP(w, r)
+		middleware.For(h, middlewares...).ServeHTTP(w, r)
 	})
-	return r
+}
+
+// configureArchiveCache returns the cache of the archives served by the
+// document root and the mounts, or nil if none of them serves archives.
+func configureArchiveCache(cfg *config.Settings) *archivefs.Cache {
+	enabled := cfg.Archives
+	for _, m := range cfg.Mounts {
+		dir, _ := filepath.Abs(m.Dir) // Already validated by New.
+		enabled = enabled || isArchive(dir)
+	}
+	if !enabled {
+		return nil
+	}
+	cache, _ := archivefs.NewCache(archiveCacheSize, cfg.ArchivesMaxSize) // Only fails with non positive sizes.
+	return cache
+}
+
+func configureUploads(cfg *config.Settings, logger *logrus.Logger, store storage.Storage, guard *symlink.Guard, digests *digest.Cache, auditSink audit.Sink) []UploadOption {
+	uploadOpts := []UploadOption{
+		WithUploadStorage(store),
+		WithStagingDir(cfg.UploadStagingDir),
+		WithExtractPolicy(extractPolicy(cfg.Extract)),
+		WithUploadGuard(guard),
+	}
+	if digests != nil {
+		uploadOpts = append(uploadOpts, WithDigests(digests))
+	}
+	if auditSink != nil {
+		logger.Infof("configuring audit log at %s", cfg.AuditLog)
+		uploadOpts = append(uploadOpts, WithAuditSink(auditSink))
+	}
+	if len(cfg.UploadPolicy) > 0 {
+		logger.Infof("configuring upload policy with %d rules", len(cfg.UploadPolicy))
+		uploadOpts = append(uploadOpts, WithUploadPolicy(uploadPolicy(cfg.UploadPolicy)))
+	}
+	if cfg.UploadScanner != "" {
+		logger.Infof("configuring upload scanning with clamd at %s", cfg.UploadScanner)
+		uploadOpts = append(uploadOpts, WithScanner(scan.NewClamd(cfg.UploadScanner), cfg.UploadScanTimeout, cfg.UploadScanFailOpen))
+	}
+	return uploadOpts
+}
+
+// withIPFilter returns a copy of the provided middlewares, with
+// the IP filter appended if there are rules.
+func withIPFilter(middlewares []middleware.Middleware, rules config.IPRules) []middleware.Middleware {
+	if !rules.Enabled() {
+		return middlewares
+	}
+	return chain(middlewares, ipFilter(rules))
+}
+
+// withAuth returns a copy of the provided middlewares, with the
+// authentication middlewares appended if it is enabled.
+func withAuth(middlewares []middleware.Middleware, a *authentication) []middleware.Middleware {
+	if !a.enabled() {
+		return chain(middlewares)
+	}
+	return chain(middlewares, append([]middleware.Middleware{a.Middleware()}, a.authenticated...)...)
+}
+
+// chain returns a new slice with the extra middlewares appended
+// to the base ones, so base can be safely shared between routes.
+func chain(base []middleware.Middleware, extra ...middleware.Middleware) []middleware.Middleware {
+	result := make([]middleware.Middleware, 0, len(base)+len(extra))
+	result = append(result, base...)
+	return append(result, extra...)
+}
+
+// configureErrorPages looks up the error pages in the configured
+// directory, or in the document root if it is not set.
+func configureErrorPages(cfg *config.Settings, logger *logrus.Logger, docRootFS http.FileSystem) *errorPages {
+	switch {
+	case !cfg.ErrorPages:
+		return newErrorPages(nil)
+	case cfg.ErrorPagesDir != "":
+		logger.Infof("configuring error pages from %s", cfg.ErrorPagesDir)
+		return newErrorPages(http.Dir(cfg.ErrorPagesDir))
+	default:
+		logger.Info("configuring error pages from the document root")
+		return newErrorPages(docRootFS)
+	}
+}
+
+func configureCompression(cfg *config.Settings, logger *logrus.Logger) *compressor {
+	logger.Infof("configuring %v compression of responses from %d bytes", cfg.CompressionEncodings, cfg.CompressionMinSize)
+	c := &compressor{
+		logger:    logger,
+		encodings: cfg.CompressionEncodings,
+		types:     cfg.CompressionTypes,
+		minSize:   cfg.CompressionMinSize,
+	}
+	if cfg.CompressionCacheSize > 0 {
+		logger.Infof("configuring compressed responses cache of %d bytes", cfg.CompressionCacheSize)
+		c.cache = compression.NewCache(cfg.CompressionCacheSize)
+	}
+	return c
+}
+
+// shared holds the limits and the authentication state of the server,
+// which the routers of all the hosts share, so they cannot be bypassed
+// or multiplied by requesting other host names.
+type shared struct {
+	authn     *authenticators
+	ipRate    *limit.Rate
+	downloads *limit.Concurrency
+	uploads   *limit.Concurrency
+}
+
+func configureShared(cfg *config.Settings, logger *logrus.Logger) *shared {
+	sh := &shared{authn: configureAuthentication(cfg, logger)}
+	if cfg.RateLimitIP > 0 {
+		logger.Infof("configuring rate limit of %v requests per second per IP", cfg.RateLimitIP)
+		sh.ipRate = limit.NewRate(cfg.RateLimitIP, cfg.RateLimitIPBurst)
+	}
+	if cfg.MaxConcurrentDownloads > 0 {
+		sh.downloads = limit.NewConcurrency(cfg.MaxConcurrentDownloads)
+	}
+	if cfg.MaxConcurrentUploads > 0 {
+		sh.uploads = limit.NewConcurrency(cfg.MaxConcurrentUploads)
+	}
+	return sh
+}
+
+func configureAuthentication(cfg *config.Settings, logger *logrus.Logger) *authenticators {
+	a := &authenticators{logger: logger}
+	if cfg.TokensFile != "" {
+		logger.Infof("configuring API tokens from %s", cfg.TokensFile)
+		a.tokens = auth.NewTokenStore(cfg.TokensFile)
+	}
+	if cfg.URLSigningKey != "" {
+		logger.Info("configuring signed URLs in server")
+		a.signer = auth.NewSigner([]byte(cfg.URLSigningKey))
+	}
+	if cfg.AuthLockoutThreshold > 0 {
+		logger.Infof("configuring authentication lockout after %d failures", cfg.AuthLockoutThreshold)
+		a.lockout = auth.NewLockout(cfg.AuthLockoutThreshold, cfg.AuthLockoutDuration, cfg.AuthLockoutMaxDuration)
+	}
+	if cfg.RateLimitUser > 0 {
+		logger.Infof("configuring rate limit of %v requests per second per user", cfg.RateLimitUser)
+		a.authenticated = append(a.authenticated, rateLimit(limit.NewRate(cfg.RateLimitUser, cfg.RateLimitUserBurst), "user", requestUser))
+	}
+	return a
+}
+
+// forHost returns a copy of the authenticators for the named host, with
+// the basic auth checkers of the authorizations of its settings.
+func (a *authenticators) forHost(name string, cfg *config.Settings, logger *logrus.Logger) *authenticators {
+	host := *a
+	host.host = name
+	if len(cfg.ReadAuthorizations) > 0 {
+		logger.Info("configuring read authorizations in server")
+		host.read = middleware.AuthChecker(readAuthConfig(cfg))
+	}
+	if len(cfg.WriteAuthorizations) > 0 {
+		logger.Info("configuring write authorizations in server")
+		host.write = middleware.AuthChecker(writeAuthConfig(cfg))
+	}
+	return &host
 }
 
 func writeAuthConfig(cfg *config.Settings) *middleware.AuthConfig {
@@ -69,20 +375,45 @@ func readAuthConfig(cfg *config.Settings) *middleware.AuthConfig {
 		WithPathRegex(".*")
 }
 
-func configureMetrics(cfg *config.Settings) []middleware.Middleware {
-	metrics.Initialize(cfg)
+func extractPolicy(settings *config.ExtractSettings) *extract.Policy {
+	return &extract.Policy{
+		Symlinks:      extract.Action(settings.Symlinks),
+		Hardlinks:     extract.Action(settings.Hardlinks),
+		Devices:       extract.Action(settings.Devices),
+		SpecialBits:   extract.Action(settings.SpecialBits),
+		AbsolutePaths: extract.Action(settings.AbsolutePaths),
+		Umask:         os.FileMode(settings.Umask),
+		ForceMode:     os.FileMode(settings.ForceMode),
+	}
+}
+
+func uploadPolicy(policy config.UploadPolicy) *upload.Policy {
+	rules := make([]upload.Rule, 0, len(policy))
+	for _, r := range policy {
+		rules = append(rules, upload.Rule{
+			Prefix:          r.Prefix,
+			AllowExtensions: r.AllowExtensions,
+			DenyExtensions:  r.DenyExtensions,
+			AllowMIMETypes:  r.AllowMIMETypes,
+			DenyMIMETypes:   r.DenyMIMETypes,
+		})
+	}
+	return upload.NewPolicy(rules)
+}
+
+func configureMetrics(cfg *config.Settings, reg prometheus.Registerer) []middleware.Middleware {
 	mapper := configureEndpointMapper(cfg)
 	var metricsMiddlewares []middleware.Middleware
 	durationObserver := middleware.RequestDurationObserver(
 		"",
-		prometheus.DefaultRegisterer,
+		reg,
 		cfg.MetricsRequestDurationBuckets,
 		mapper,
 	)
 	metricsMiddlewares = append(metricsMiddlewares, durationObserver)
 	responseSizeObserver := middleware.ResponseSizeObserver(
 		"",
-		prometheus.DefaultRegisterer,
+		reg,
 		cfg.MetricsSizeBuckets,
 		mapper,
 	)
@@ -92,6 +423,9 @@ func configureMetrics(cfg *config.Settings) []middleware.Middleware {
 func configureEndpointMapper(cfg *config.Settings) *endpointMapper {
 	em := newEndpointMapper()
 	em.Declare(cfg.Prefix, cfg.Prefix)
+	for _, m := range cfg.Mounts {
+		em.Declare(m.Prefix, m.Prefix)
+	}
 	if cfg.UploadEndpoint != "" {
 		em.Declare(cfg.UploadEndpoint, cfg.UploadEndpoint)
 	}
@@ -13,8 +13,16 @@ import (
 
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/sirupsen/logrus"
+	"go.eloylp.dev/kit/http/middleware"
 
+	"go.eloylp.dev/go-serve/audit"
+	"go.eloylp.dev/go-serve/compression"
 	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/headers"
+	"go.eloylp.dev/go-serve/hidden"
+	"go.eloylp.dev/go-serve/redirect"
+	"go.eloylp.dev/go-serve/storage"
+	"go.eloylp.dev/go-serve/symlink"
 )
 
 type Server struct {
@@ -23,6 +31,7 @@ type Server struct {
 	internalHTTPServer           *http.Server
 	alternativeMetricsHTTPServer *http.Server
 	logger                       *logrus.Logger
+	auditFile                    *os.File
 	cfg                          *config.Settings
 	wg                           *sync.WaitGroup
 	ctx                          context.Context
@@ -39,12 +48,75 @@ func New(cfg *config.Settings) (*Server, error) {
 	if err != nil {
 		return nil, fmt.Errorf("go-serve: %w", err)
 	}
-	handler := router(cfg, logger, docRoot, Info{
+	if err := extractPolicy(cfg.Extract).Validate(); err != nil {
+		return nil, fmt.Errorf("go-serve: %w", err)
+	}
+	if err := symlink.Policy(cfg.Symlinks).Validate(); err != nil {
+		return nil, fmt.Errorf("go-serve: %w", err)
+	}
+	if _, err := hidden.New(cfg.HiddenFiles); err != nil {
+		return nil, fmt.Errorf("go-serve: %w", err)
+	}
+	for _, p := range []config.CORSPolicy{cfg.CORS.Files, cfg.CORS.Upload, cfg.CORS.Download} {
+		if _, err := corsFrom(p); err != nil {
+			return nil, fmt.Errorf("go-serve: %w", err)
+		}
+	}
+	if err := validateMounts(cfg); err != nil {
+		return nil, fmt.Errorf("go-serve: %w", err)
+	}
+	if err := validateVirtualHosts(cfg.VirtualHosts); err != nil {
+		return nil, fmt.Errorf("go-serve: %w", err)
+	}
+	redirectRules, err := redirect.Parse(cfg.Redirects)
+	if err != nil {
+		return nil, fmt.Errorf("go-serve: %w", err)
+	}
+	if _, err := redirect.New(redirectRules); err != nil {
+		return nil, fmt.Errorf("go-serve: %w", err)
+	}
+	if _, err := headers.New(headerRulesFrom(cfg.HeaderRules)); err != nil {
+		return nil, fmt.Errorf("go-serve: %w", err)
+	}
+	if _, err := indexTemplate(cfg.ListingTemplate); err != nil {
+		return nil, fmt.Errorf("go-serve: %w", err)
+	}
+	if _, err := listingDisabled(cfg.ListingDisabled); err != nil {
+		return nil, fmt.Errorf("go-serve: %w", err)
+	}
+	for _, e := range cfg.CompressionEncodings {
+		if !compression.Supported(e) {
+			return nil, fmt.Errorf("go-serve: unsupported compression encoding %q", e)
+		}
+	}
+	if cfg.ErrorPages && cfg.ErrorPagesDir != "" {
+		if info, err := os.Stat(cfg.ErrorPagesDir); err != nil || !info.IsDir() {
+			return nil, fmt.Errorf("go-serve: error pages directory %s is not accessible", cfg.ErrorPagesDir)
+		}
+	}
+	if cfg.Archives && cfg.ArchivesMaxSize <= 0 {
+		return nil, fmt.Errorf("go-serve: archives max size must be positive")
+	}
+	if _, local := cfg.Storage.(*storage.Local); cfg.Storage != nil && !local && symlink.Policy(cfg.Symlinks) != symlink.Inside {
+		return nil, fmt.Errorf("go-serve: symlinks policies only apply to the local storage")
+	}
+	if cfg.Archives && cfg.Storage != nil {
+		return nil, fmt.Errorf("go-serve: archives are only served from the local document root")
+	}
+	var auditFile *os.File
+	var auditSink audit.Sink
+	if cfg.AuditLog != "" {
+		if auditFile, err = audit.OpenFile(cfg.AuditLog); err != nil {
+			return nil, fmt.Errorf("go-serve: %w", err)
+		}
+		auditSink = audit.NewJSONLines(auditFile)
+	}
+	handler := serverHandler(cfg, logger, docRoot, Info{
 		Name:      Name,
 		Version:   Version,
 		Build:     Build,
 		BuildTime: BuildTime,
-	})
+	}, auditSink)
 	s := &http.Server{
 		Addr:         cfg.ListenAddr,
 		Handler:      handler,
@@ -56,6 +128,7 @@ func New(cfg *config.Settings) (*Server, error) {
 		identity:           identity,
 		internalHTTPServer: s,
 		logger:             logger,
+		auditFile:          auditFile,
 		cfg:                cfg,
 		wg:                 &sync.WaitGroup{},
 		servingRoot:        docRoot,
@@ -85,7 +158,7 @@ func (s *Server) startAlternateMetricsServer() {
 	s.logger.Infof("starting to serve metrics at %s ...", s.cfg.MetricsListenAddr)
 	h := promhttp.Handler()
 	mux := http.NewServeMux()
-	mux.Handle(s.cfg.MetricsPath, h)
+	mux.Handle(s.cfg.MetricsPath, middleware.For(h, accessMiddlewares(s.cfg, s.cfg.Access.Metrics)...))
 	s.alternativeMetricsHTTPServer = &http.Server{
 		Handler: mux,
 		Addr:    s.cfg.MetricsListenAddr,
@@ -127,6 +200,11 @@ func (s *Server) Shutdown(ctx context.Context) error {
 			return fmt.Errorf("go-serve: metrics: shutdown: %w", err)
 		}
 	}
+	if s.auditFile != nil {
+		if err := s.auditFile.Close(); err != nil {
+			return fmt.Errorf("go-serve: audit: %w", err)
+		}
+	}
 	s.logger.Info("server is now shutdown !")
 	return nil
 }
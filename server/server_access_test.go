@@ -0,0 +1,87 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func ipNets(t *testing.T, value string) config.IPNets {
+	var n config.IPNets
+	require.NoError(t, n.Decode(value))
+	return n
+}
+
+func TestUploadIsRestrictedToAllowedNetworks(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t, config.WithAccess(&config.AccessSettings{
+		Upload: config.IPRules{Allow: ipNets(t, "10.0.0.0/8")},
+	}))
+
+	defer s.Shutdown(context.Background())
+
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, sampleTARGZContentReader())
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/tar+gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	// Other endpoint classes are not affected.
+	resp, err = http.Get(HTTPAddressStatic + "/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestMetricsAndStatusCanBeDenied(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t, config.WithAccess(&config.AccessSettings{
+		Metrics: config.IPRules{Deny: ipNets(t, "127.0.0.1")},
+		Status:  config.IPRules{Allow: ipNets(t, "192.168.0.0/16")},
+	}))
+
+	defer s.Shutdown(context.Background())
+
+	resp, err := http.Get(HTTPAddress + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp, err = http.Get(HTTPAddressStatus)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
+
+func TestClientIPIsTakenFromTrustedProxies(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t,
+		config.WithTrustedProxies(ipNets(t, "127.0.0.1")),
+		config.WithAccess(&config.AccessSettings{
+			Upload: config.IPRules{Allow: ipNets(t, "10.0.0.0/8")},
+		}),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, sampleTARGZContentReader())
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/tar+gzip")
+	req.Header.Add("X-Forwarded-For", "10.1.2.3")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,173 @@
+//+build integration
+
+package server_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/server"
+)
+
+var archiveMembers = map[string]string{
+	"docs/index.html":      "<h1>Docs</h1>",
+	"docs/guide/intro.txt": "0123456789abcdefghij",
+	".env":                 "SECRET=1",
+}
+
+func writeZip(t *testing.T, path string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range archiveMembers {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = io.WriteString(w, content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+}
+
+func writeTarGz(t *testing.T, path string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	for name, content := range archiveMembers {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}))
+		_, err = io.WriteString(tw, content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+}
+
+func TestArchives(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithArchives(true, 1<<20))
+
+	defer s.Shutdown(context.Background())
+
+	writeZip(t, filepath.Join(docRoot, "builds", "v1.2.3.zip"))
+	writeTarGz(t, filepath.Join(docRoot, "builds", "v1.2.4.tar.gz"))
+
+	for _, archive := range []string{"/builds/v1.2.3.zip", "/builds/v1.2.4.tar.gz"} {
+		base := HTTPAddressStatic + archive + "/!"
+		assert.Equal(t, "<h1>Docs</h1>", string(BodyFrom(t, base+"/docs/index.html")))
+		assert.Equal(t, "<h1>Docs</h1>", string(BodyFrom(t, base+"/docs/")))
+		assert.Equal(t, "0123456789abcdefghij", string(BodyFrom(t, base+"/docs/guide/intro.txt")))
+		assert.Equal(t, http.StatusNotFound, statusOf(t, base+"/docs/missing.txt"))
+		assert.Equal(t, http.StatusNotFound, statusOf(t, base+"/.env"))
+
+		status, listing := listingOf(t, base+"/")
+		require.Equal(t, http.StatusOK, status)
+		assert.Equal(t, archive+"/!", listing.Path)
+		assert.Equal(t, []string{archive + "/!/docs"}, entryPaths(listing))
+
+		_, listing = listingOf(t, base+"/docs/?depth=2")
+		assert.Equal(t, []string{archive + "/!/docs/guide", archive + "/!/docs/guide/intro.txt", archive + "/!/docs/index.html"}, entryPaths(listing))
+		assert.Contains(t, string(BodyFrom(t, base+"/docs/guide/")), `<a href="intro.txt">intro.txt</a>`)
+	}
+}
+
+func TestArchivesServeRanges(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithArchives(true, 1<<20))
+
+	defer s.Shutdown(context.Background())
+
+	writeZip(t, filepath.Join(docRoot, "v1.zip"))
+
+	req, err := http.NewRequest(http.MethodGet, HTTPAddressStatic+"/v1.zip/!/docs/guide/intro.txt", nil)
+	require.NoError(t, err)
+	req.Header.Set("Range", "bytes=10-14")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "abcde", string(body))
+}
+
+func TestArchivesAreDisabledByDefault(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	writeZip(t, filepath.Join(docRoot, "v1.zip"))
+
+	assert.Equal(t, http.StatusNotFound, statusOf(t, HTTPAddressStatic+"/v1.zip/!/docs/index.html"))
+	assert.Equal(t, http.StatusOK, statusOf(t, HTTPAddressStatic+"/v1.zip"))
+}
+
+func TestArchivesTooLarge(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithArchives(true, 16))
+
+	defer s.Shutdown(context.Background())
+
+	writeTarGz(t, filepath.Join(docRoot, "v1.tar.gz"))
+	writeZip(t, filepath.Join(docRoot, "v1.zip"))
+
+	assert.Equal(t, http.StatusInternalServerError, statusOf(t, HTTPAddressStatic+"/v1.tar.gz/!/docs/index.html"))
+	assert.Equal(t, http.StatusOK, statusOf(t, HTTPAddressStatic+"/v1.zip/!/docs/index.html"))
+}
+
+func TestArchiveMounts(t *testing.T) {
+	BeforeEach(t)
+
+	archive := filepath.Join(t.TempDir(), "site.tar.gz")
+	writeTarGz(t, archive)
+
+	s, _, _ := sut(t, config.WithMounts(&config.Mount{
+		Name:            "site",
+		Prefix:          "/site",
+		Dir:             archive,
+		ReadOnly:        true,
+		ListingDisabled: []string{"docs/guide"},
+	}))
+
+	defer s.Shutdown(context.Background())
+
+	assert.Equal(t, "<h1>Docs</h1>", string(BodyFrom(t, HTTPAddress+"/site/docs/")))
+	assert.Equal(t, "0123456789abcdefghij", string(BodyFrom(t, HTTPAddress+"/site/docs/guide/intro.txt")))
+	assert.Equal(t, http.StatusForbidden, statusOf(t, HTTPAddress+"/site/docs/guide/"))
+	assert.Equal(t, http.StatusNotFound, mountUpload(t, HTTPAddress+"/site", "docs/new.txt", "new"))
+
+	status, listing := listingOf(t, HTTPAddress+"/site/")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"/docs"}, entryPaths(listing))
+}
+
+func TestArchiveMountsMustBeReadOnly(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "site.zip")
+	writeZip(t, archive)
+
+	_, err := server.New(config.ForOptions(config.WithDocRoot(t.TempDir()), config.WithMounts(&config.Mount{
+		Name:   "site",
+		Prefix: "/site",
+		Dir:    archive,
+	})))
+	assert.Error(t, err)
+}
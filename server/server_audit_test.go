@@ -0,0 +1,105 @@
+//+build integration
+
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/audit"
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/extract"
+)
+
+func auditEvents(t *testing.T, path string) []audit.Event {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var events []audit.Event
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e audit.Event
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		events = append(events, e)
+	}
+	return events
+}
+
+func TestUploadsAreAudited(t *testing.T) {
+	BeforeEach(t)
+
+	auditLog := filepath.Join(t.TempDir(), "audit.log")
+	s, _, _ := sut(t,
+		config.WithAuditLog(auditLog),
+		config.WithWriteAuthorizations(testUserCredentials),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	upload := func(deployPath string) {
+		req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, sampleTARGZContentReader())
+		require.NoError(t, err)
+		req.Header.Add("Content-Type", "application/tar+gzip")
+		req.Header.Add(DeployPathHeader, deployPath)
+		req.SetBasicAuth("user", "password")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+	}
+	upload("/v1")
+	upload("/v1")
+	upload("..")
+
+	require.NoError(t, s.Shutdown(context.Background()))
+	events := auditEvents(t, auditLog)
+	require.Len(t, events, 3)
+
+	sum := sha256.Sum256(sampleTARGZContent)
+	first := events[0]
+	assert.Equal(t, "upload", first.Action)
+	assert.Equal(t, "user", first.User)
+	assert.Equal(t, "127.0.0.1", first.ClientIP)
+	assert.Equal(t, "/v1", first.Path)
+	assert.Equal(t, int64(533766), first.Bytes)
+	assert.Equal(t, hex.EncodeToString(sum[:]), first.SHA256)
+	assert.Equal(t, audit.OutcomeSuccess, first.Outcome)
+	assert.Contains(t, first.Files, extract.File{Path: "/v1/notes/notes.txt", Bytes: 20})
+
+	assert.Contains(t, events[1].Files, extract.File{Path: "/v1/notes/notes.txt", Bytes: 20, Overwritten: true})
+
+	assert.Equal(t, audit.OutcomeFailure, events[2].Outcome)
+	assert.NotEmpty(t, events[2].Error)
+}
+
+func TestSingleFileUploadIsAudited(t *testing.T) {
+	BeforeEach(t)
+
+	auditLog := filepath.Join(t.TempDir(), "audit.log")
+	s, _, _ := sut(t, config.WithAuditLog(auditLog))
+
+	defer s.Shutdown(context.Background())
+
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, bytes.NewReader([]byte("hello")))
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/octet-stream")
+	req.Header.Add(DeployPathHeader, "/notes/hello.txt")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, s.Shutdown(context.Background()))
+	events := auditEvents(t, auditLog)
+	require.Len(t, events, 1)
+	assert.Empty(t, events[0].User)
+	assert.Equal(t, []extract.File{{Path: "/notes/hello.txt", Bytes: 5}}, events[0].Files)
+}
@@ -0,0 +1,166 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+var compressibleContent = strings.Repeat("body { color: black; }\n", 200)
+
+func compressionDocRoot(t *testing.T, docRoot string) {
+	require.NoError(t, os.WriteFile(filepath.Join(docRoot, "style.css"), []byte(compressibleContent), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(docRoot, "small.css"), []byte("body {}"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(docRoot, "image.png"), []byte(compressibleContent), 0600))
+}
+
+func TestCompressionOnTheFly(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithCompression(1024, 1<<20))
+
+	defer s.Shutdown(context.Background())
+
+	compressionDocRoot(t, docRoot)
+
+	resp, body := precompressedGet(t, HTTPAddressStatic+"/style.css", "gzip, br")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "br", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "text/css; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+	assert.Empty(t, resp.Header.Get("Accept-Ranges"))
+	decoded, err := io.ReadAll(brotli.NewReader(strings.NewReader(body)))
+	require.NoError(t, err)
+	assert.Equal(t, compressibleContent, string(decoded))
+
+	resp, body = precompressedGet(t, HTTPAddressStatic+"/style.css", "zstd")
+	assert.Equal(t, "zstd", resp.Header.Get("Content-Encoding"))
+	zr, err := zstd.NewReader(strings.NewReader(body))
+	require.NoError(t, err)
+	decoded, err = io.ReadAll(zr)
+	require.NoError(t, err)
+	assert.Equal(t, compressibleContent, string(decoded))
+
+	resp, body = precompressedGet(t, HTTPAddressStatic+"/style.css", "")
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, compressibleContent, body)
+}
+
+func TestCompressionSkipsSmallAndIncompressibleContent(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithCompression(1024, 1<<20))
+
+	defer s.Shutdown(context.Background())
+
+	compressionDocRoot(t, docRoot)
+
+	resp, body := precompressedGet(t, HTTPAddressStatic+"/small.css", "gzip")
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "body {}", body)
+
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/image.png", "gzip")
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+
+	resp, body = precompressedGet(t, HTTPAddressStatic+"/style.css", "gzip", "Range", "bytes=0-3")
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "body", body)
+}
+
+func TestCompressionCacheMetrics(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithCompression(1024, 1<<20), config.WithMetricsEnabled(true))
+
+	defer s.Shutdown(context.Background())
+
+	compressionDocRoot(t, docRoot)
+
+	_, first := precompressedGet(t, HTTPAddressStatic+"/style.css", "gzip")
+	_, second := precompressedGet(t, HTTPAddressStatic+"/style.css", "gzip")
+	assert.Equal(t, first, second)
+
+	metrics := string(BodyFrom(t, HTTPAddress+"/metrics"))
+	assert.Contains(t, metrics, `http_compression_cache_requests_total{result="hit"} 1`)
+	assert.Contains(t, metrics, `http_compression_cache_requests_total{result="miss"} 1`)
+}
+
+func TestCompressionIsDisabledByDefault(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	compressionDocRoot(t, docRoot)
+
+	resp, body := precompressedGet(t, HTTPAddressStatic+"/style.css", "gzip")
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, compressibleContent, body)
+}
+
+func TestCompressionCacheIsKeyedByRequestPath(t *testing.T) {
+	BeforeEach(t)
+
+	mounts := make([]*config.Mount, 0, 2)
+	modTime := time.Now().Add(-time.Hour)
+	for _, name := range []string{"assets", "themes"} {
+		dir := t.TempDir()
+		content := strings.Repeat(name+" { color: black; }\n", 200)
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "style.css"), []byte(content), 0600))
+		require.NoError(t, os.Chtimes(filepath.Join(dir, "style.css"), modTime, modTime))
+		mounts = append(mounts, &config.Mount{Name: name, Prefix: "/" + name, Dir: dir, ReadOnly: true})
+	}
+
+	s, _, _ := sut(t, config.WithCompression(1024, 1<<20), config.WithETags(false), config.WithMounts(mounts...))
+
+	defer s.Shutdown(context.Background())
+
+	for _, name := range []string{"assets", "themes", "assets", "themes"} {
+		resp, body := precompressedGet(t, HTTPAddress+"/"+name+"/style.css", "br")
+		assert.Equal(t, "br", resp.Header.Get("Content-Encoding"))
+		decoded, err := io.ReadAll(brotli.NewReader(strings.NewReader(body)))
+		require.NoError(t, err)
+		assert.Equal(t, strings.Repeat(name+" { color: black; }\n", 200), string(decoded))
+	}
+}
+
+func TestCompressionCacheIsKeyedByExactModificationTime(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithCompression(1024, 1<<20))
+
+	defer s.Shutdown(context.Background())
+
+	// Both versions have the same size and Last-Modified header,
+	// as they are modified within the same second.
+	second := time.Now().Add(-time.Hour).Truncate(time.Second)
+	path := filepath.Join(docRoot, "style.css")
+	for i, name := range []string{"first", "other"} {
+		content := strings.Repeat(name+" { color: black; }\n", 200)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		modTime := second.Add(time.Duration(i) * 500 * time.Millisecond)
+		require.NoError(t, os.Chtimes(path, modTime, modTime))
+
+		resp, body := precompressedGet(t, HTTPAddressStatic+"/style.css", "br")
+		assert.Equal(t, "br", resp.Header.Get("Content-Encoding"))
+		decoded, err := io.ReadAll(brotli.NewReader(strings.NewReader(body)))
+		require.NoError(t, err)
+		assert.Equal(t, content, string(decoded))
+	}
+}
@@ -0,0 +1,105 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/server"
+)
+
+func corsRequest(t *testing.T, method, url, origin string, headers ...string) *http.Response {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Origin", origin)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp
+}
+
+func TestCORSPreflightRunsBeforeAuthentication(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t,
+		config.WithWriteAuthorizations(testUserCredentials),
+		config.WithCORS(&config.CORSSettings{
+			Upload: config.CORSPolicy{
+				AllowOrigins:     []string{"https://app.example.com"},
+				AllowHeaders:     []string{"Authorization", "Content-Type", DeployPathHeader},
+				AllowCredentials: true,
+				MaxAge:           time.Hour,
+			},
+		}),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	resp := corsRequest(t, http.MethodOptions, HTTPAddressUpload, "https://app.example.com",
+		"Access-Control-Request-Method", "POST",
+		"Access-Control-Request-Headers", "authorization,content-type",
+	)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", resp.Header.Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "POST", resp.Header.Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Authorization, Content-Type", resp.Header.Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "3600", resp.Header.Get("Access-Control-Max-Age"))
+
+	resp = corsRequest(t, http.MethodOptions, HTTPAddressUpload, "https://evil.example.com", "Access-Control-Request-Method", "POST")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+
+	// Authentication errors are readable by the allowed origins.
+	resp = corsRequest(t, http.MethodPost, HTTPAddressUpload, "https://app.example.com")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "https://app.example.com", resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSPolicyIsPerEndpointClass(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithCORS(&config.CORSSettings{
+		Files: config.CORSPolicy{AllowOrigins: []string{"*"}, ExposeHeaders: []string{"ETag"}},
+	}))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	resp := corsRequest(t, http.MethodGet, HTTPAddressStatic+"/notes/notes.txt", "https://app.example.com")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "*", resp.Header.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "ETag", resp.Header.Get("Access-Control-Expose-Headers"))
+
+	resp = corsRequest(t, http.MethodOptions, HTTPAddressStatic+"/notes/notes.txt", "https://app.example.com", "Access-Control-Request-Method", "GET")
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, "GET, HEAD", resp.Header.Get("Access-Control-Allow-Methods"))
+
+	resp = corsRequest(t, http.MethodOptions, HTTPAddressStatic+"/notes/notes.txt", "https://app.example.com", "Access-Control-Request-Method", "POST")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	resp = corsRequest(t, http.MethodOptions, HTTPAddressUpload, "https://app.example.com", "Access-Control-Request-Method", "POST")
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+
+	resp = corsRequest(t, http.MethodGet, HTTPAddressDownload, "https://app.example.com")
+	assert.Empty(t, resp.Header.Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSAnyOriginWithCredentialsFailsOnStart(t *testing.T) {
+	_, err := server.New(config.ForOptions(config.WithDocRoot(t.TempDir()), config.WithCORS(&config.CORSSettings{
+		Download: config.CORSPolicy{AllowOrigins: []string{"*"}, AllowCredentials: true},
+	})))
+	assert.Error(t, err)
+}
@@ -0,0 +1,133 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/server"
+)
+
+func errorResponse(t *testing.T, method, url, accept string) (*http.Response, string) {
+	req, err := http.NewRequest(method, url, nil)
+	require.NoError(t, err)
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func writeErrorPage(t *testing.T, dir, name, content string) {
+	require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0600))
+}
+
+func TestErrorPagesFromDocRoot(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithErrorPages(""))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+	writeErrorPage(t, docRoot, "404.html", "root {{.Status}} {{.Code}} {{.Path}}")
+	writeErrorPage(t, docRoot, "notes/404.html", "notes {{.Message}}")
+
+	resp, body := errorResponse(t, http.MethodGet, HTTPAddressStatic+"/missing.txt", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "root 404 not_found /missing.txt", body)
+
+	_, body = errorResponse(t, http.MethodGet, HTTPAddressStatic+"/notes/subnotes/missing.txt", "")
+	assert.Equal(t, "notes Not Found", body)
+
+	_, body = errorResponse(t, http.MethodGet, "http://"+ListenAddress+"/unknown/route", "")
+	assert.Equal(t, "root 404 not_found /unknown/route", body)
+}
+
+func TestErrorPagesFromDirectory(t *testing.T) {
+	BeforeEach(t)
+
+	pages := t.TempDir()
+	writeErrorPage(t, pages, "401.html", "please log in")
+	writeErrorPage(t, pages, "404.html", "<p>{{.Path}}</p>")
+
+	s, _, _ := sut(t, config.WithErrorPages(pages), config.WithReadAuthorizations(testUserCredentials))
+
+	defer s.Shutdown(context.Background())
+
+	resp, body := errorResponse(t, http.MethodGet, HTTPAddressStatic+"/", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	assert.Equal(t, "please log in", body)
+
+	req, err := http.NewRequest(http.MethodGet, HTTPAddressStatic+"/<script>", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("user", "password")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "<p>/&lt;script&gt;</p>", string(data))
+}
+
+func TestErrorPagesKeepPlainTextWithoutPage(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t, config.WithErrorPages(t.TempDir()))
+
+	defer s.Shutdown(context.Background())
+
+	resp, body := errorResponse(t, http.MethodGet, HTTPAddressStatic+"/missing.txt", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "404 page not found\n", body)
+}
+
+func TestErrorsAreJSONWhenPreferred(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	cases := []struct {
+		method string
+		url    string
+		status int
+		code   string
+	}{
+		{http.MethodGet, HTTPAddressStatic + "/missing.txt", http.StatusNotFound, "not_found"},
+		{http.MethodGet, HTTPAddressDownload + "?path=/missing", http.StatusNotFound, "not_found"},
+		{http.MethodPost, HTTPAddressUpload, http.StatusNotFound, "not_found"},
+	}
+	for _, c := range cases {
+		resp, body := errorResponse(t, c.method, c.url, "application/json")
+		assert.Equal(t, c.status, resp.StatusCode, c.url)
+		assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+		var result map[string]server.APIError
+		require.NoError(t, json.Unmarshal([]byte(body), &result))
+		assert.Equal(t, server.APIError{Status: c.status, Code: c.code, Message: http.StatusText(c.status)}, result["error"])
+	}
+}
+
+func TestErrorPagesBadDirectoryFailsOnStart(t *testing.T) {
+	_, err := server.New(config.ForOptions(config.WithDocRoot(t.TempDir()), config.WithErrorPages("/non/existent")))
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+//+build integration
+
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func sha256From(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestStaticFilesHaveContentETags(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithETags(true))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	content, err := os.ReadFile(filepath.Join(DocRoot, "notes", "notes.txt"))
+	require.NoError(t, err)
+	etag := `"` + sha256From(content) + `"`
+
+	resp, _ := precompressedGet(t, HTTPAddressStatic+"/notes/notes.txt", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, etag, resp.Header.Get("ETag"))
+
+	resp, body := precompressedGet(t, HTTPAddressStatic+"/notes/notes.txt", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+	assert.Empty(t, body)
+
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/notes/notes.txt", "", "If-None-Match", `"other"`)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestStaticFilesETagsIgnoreModificationTimes(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithETags(true))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+	path := filepath.Join(docRoot, "notes", "notes.txt")
+
+	resp, _ := precompressedGet(t, HTTPAddressStatic+"/notes/notes.txt", "")
+	etag := resp.Header.Get("ETag")
+
+	// A redeploy resets the modification time, but keeps the content.
+	later := time.Now().Add(time.Hour)
+	require.NoError(t, os.Chtimes(path, later, later))
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/notes/notes.txt", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+
+	require.NoError(t, os.WriteFile(path, []byte("changed"), 0600))
+	resp, body := precompressedGet(t, HTTPAddressStatic+"/notes/notes.txt", "", "If-None-Match", etag)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "changed", body)
+	assert.Equal(t, `"`+sha256From([]byte("changed"))+`"`, resp.Header.Get("ETag"))
+}
+
+func TestPrecompressedAndCompressedETags(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithCompression(1024, 1<<20), config.WithPrecompressed(true), config.WithETags(true))
+
+	defer s.Shutdown(context.Background())
+
+	sidecarsDocRoot(t, docRoot)
+	compressionDocRoot(t, docRoot)
+
+	resp, _ := precompressedGet(t, HTTPAddressStatic+"/app.js", "br")
+	assert.Equal(t, `"`+sha256From([]byte("brotli content"))+`"`, resp.Header.Get("ETag"))
+
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/style.css", "gzip")
+	etag := resp.Header.Get("ETag")
+	assert.Equal(t, `W/"`+sha256From([]byte(compressibleContent))+`"`, etag)
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/style.css", "gzip", "If-None-Match", etag)
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestUploadsPrecomputeETags(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t, config.WithETags(true))
+
+	defer s.Shutdown(context.Background())
+
+	content := []byte("uploaded content")
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, bytes.NewReader(content))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(DeployPathHeader, "/docs/file.txt")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, listing := listingOf(t, HTTPAddressStatic+"/docs/")
+	require.Len(t, listing.Entries, 1)
+	assert.Equal(t, sha256From(content), listing.Entries[0].SHA256)
+}
+
+func TestETagsAreDisabledByDefault(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	resp, _ := precompressedGet(t, HTTPAddressStatic+"/notes/notes.txt", "")
+	assert.Empty(t, resp.Header.Get("ETag"))
+}
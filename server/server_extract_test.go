@@ -0,0 +1,99 @@
+//+build integration
+
+package server_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/extract"
+)
+
+func tarGzipOf(t *testing.T, headers ...*tar.Header) *bytes.Buffer {
+	buf := bytes.NewBuffer(nil)
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for _, h := range headers {
+		require.NoError(t, tw.WriteHeader(h))
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf
+}
+
+func uploadTARGZ(t *testing.T, deployPath string, body *bytes.Buffer) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, body)
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/tar+gzip")
+	req.Header.Add(DeployPathHeader, deployPath)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestExtractionPolicyRejectsOutsideSymlinks(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	resp := uploadTARGZ(t, "/v1", tarGzipOf(t,
+		&tar.Header{Name: "empty.txt", Typeflag: tar.TypeReg, Mode: 0644},
+		&tar.Header{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+	))
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	var body struct {
+		Error     string             `json:"error"`
+		Decisions []extract.Decision `json:"decisions"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "extraction policy violation", body.Error)
+	assert.Equal(t, []extract.Decision{{Path: "passwd", Case: "symlink", Action: "reject"}}, body.Decisions)
+	assert.NoDirExists(t, filepath.Join(docRoot, "v1"))
+}
+
+func TestExtractionPolicyDecisionsAreReported(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithExtract(&config.ExtractSettings{
+		Symlinks:      "sanitize",
+		Hardlinks:     "skip",
+		Devices:       "skip",
+		SpecialBits:   "sanitize",
+		AbsolutePaths: "sanitize",
+	}))
+
+	defer s.Shutdown(context.Background())
+
+	resp := uploadTARGZ(t, "/v1", tarGzipOf(t,
+		&tar.Header{Name: "/abs.txt", Typeflag: tar.TypeReg, Mode: 04755},
+		&tar.Header{Name: "passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"},
+		&tar.Header{Name: "null", Typeflag: tar.TypeChar, Devmajor: 1, Devminor: 3},
+	))
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	data, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "upload complete ! Bytes written: 0\n"+
+		"sanitize absolute_path entry /abs.txt\n"+
+		"sanitize special_bits entry /abs.txt\n"+
+		"sanitize symlink entry passwd\n"+
+		"skip device entry null", string(data))
+	assert.FileExists(t, filepath.Join(docRoot, "v1", "abs.txt"))
+	assert.NoFileExists(t, filepath.Join(docRoot, "v1", "null"))
+}
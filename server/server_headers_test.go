@@ -0,0 +1,75 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/server"
+)
+
+func TestHeaderRules(t *testing.T) {
+	BeforeEach(t)
+
+	rules := config.HeaderRules{}
+	require.NoError(t, rules.Decode(`
+		/static/** set Strict-Transport-Security: max-age=63072000
+		/static/notes/** set Cache-Control: immutable, max-age=31536000
+		index.html set Cache-Control: no-cache
+		~\.txt$ append Content-Security-Policy: default-src 'self'
+		/** remove Accept-Ranges
+	`))
+	s, _, docRoot := sut(t, config.WithHeaderRules(rules))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+	require.NoError(t, os.WriteFile(filepath.Join(docRoot, "notes", "index.html"), []byte("notes index"), 0600))
+
+	resp, _ := precompressedGet(t, HTTPAddressStatic+"/notes/notes.txt", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "max-age=63072000", resp.Header.Get("Strict-Transport-Security"))
+	assert.Equal(t, "immutable, max-age=31536000", resp.Header.Get("Cache-Control"))
+	assert.Equal(t, "default-src 'self'", resp.Header.Get("Content-Security-Policy"))
+	assert.Empty(t, resp.Header.Get("Accept-Ranges"))
+
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/notes/index.html", "")
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+	assert.Empty(t, resp.Header.Get("Content-Security-Policy"))
+
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/gnu.png", "")
+	assert.Empty(t, resp.Header.Get("Cache-Control"))
+	assert.Equal(t, "max-age=63072000", resp.Header.Get("Strict-Transport-Security"))
+
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/missing.txt", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	assert.Equal(t, "default-src 'self'", resp.Header.Get("Content-Security-Policy"))
+
+	resp, _ = precompressedGet(t, HTTPAddress+"/status", "")
+	assert.Empty(t, resp.Header.Get("Strict-Transport-Security"))
+}
+
+func TestHeaderRulesBadPatternFailsOnStart(t *testing.T) {
+	_, err := server.New(config.ForOptions(
+		config.WithDocRoot(t.TempDir()),
+		config.WithHeaderRules(config.HeaderRules{{Pattern: "~(", Action: "set", Name: "X-Foo", Value: "bar"}}),
+	))
+	assert.Error(t, err)
+}
+
+func TestHeaderRulesBadNameFailsOnStart(t *testing.T) {
+	_, err := server.New(config.ForOptions(
+		config.WithDocRoot(t.TempDir()),
+		config.WithHeaderRules(config.HeaderRules{{Pattern: "/**", Action: "set", Name: "Cache Control", Value: "no-cache"}}),
+	))
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func hiddenDocRoot(t *testing.T, docRoot string) {
+	test.Copy(t, DocRoot, docRoot)
+	require.NoError(t, os.MkdirAll(filepath.Join(docRoot, ".git"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(docRoot, ".git", "config"), []byte("[core]"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(docRoot, "notes", ".env"), []byte("SECRET=1"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(docRoot, ".well-known"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(docRoot, ".well-known", "security.txt"), []byte("contact"), 0600))
+}
+
+func TestHiddenFilesAreNotServed(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	hiddenDocRoot(t, docRoot)
+
+	assert.Equal(t, http.StatusNotFound, statusOf(t, HTTPAddressStatic+"/.git/config"))
+	assert.Equal(t, http.StatusNotFound, statusOf(t, HTTPAddressStatic+"/.git/"))
+	assert.Equal(t, http.StatusNotFound, statusOf(t, HTTPAddressStatic+"/notes/.env"))
+	assert.Equal(t, http.StatusOK, statusOf(t, HTTPAddressStatic+"/.well-known/security.txt"))
+	assert.Equal(t, http.StatusOK, statusOf(t, HTTPAddressStatic+"/notes/notes.txt"))
+
+	listing := string(BodyFrom(t, HTTPAddressStatic+"/"))
+	assert.NotContains(t, listing, ".git")
+	assert.Contains(t, listing, ".well-known")
+	assert.NotContains(t, string(BodyFrom(t, HTTPAddressStatic+"/notes/")), ".env")
+
+	req, err := http.NewRequest(http.MethodGet, HTTPAddressDownload, nil)
+	require.NoError(t, err)
+	req.Header.Add("Accept", "application/tar+gzip")
+	req.Header.Add(DownloadPathHeader, "/notes")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	AssertTARGZMD5Sums(t, resp.Body, map[string]string{
+		".":                  "",
+		"notes.txt":          NotesTestFileMD5,
+		"subnotes":           "",
+		"subnotes/notes.txt": SubNotesTestFileMD5,
+	})
+
+	req.Header.Set(DownloadPathHeader, "/.git")
+	resp2, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp2.StatusCode)
+}
+
+func TestHiddenFilesCanBeConfigured(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithHiddenFiles("*.png"))
+
+	defer s.Shutdown(context.Background())
+
+	hiddenDocRoot(t, docRoot)
+
+	assert.Equal(t, http.StatusOK, statusOf(t, HTTPAddressStatic+"/.git/config"))
+	assert.Equal(t, http.StatusNotFound, statusOf(t, HTTPAddressStatic+"/gnu.png"))
+}
@@ -0,0 +1,108 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func TestRateLimitPerIP(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t, config.WithRateLimitIP(0.01, 2))
+
+	defer s.Shutdown(context.Background())
+
+	for i := 0; i < 2; i++ {
+		resp, err := http.Get(HTTPAddressStatic + "/")
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	resp, err := http.Get(HTTPAddressStatic + "/")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "100", resp.Header.Get("Retry-After"))
+
+	resp, err = http.Get(HTTPAddress + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `http_rate_limit_rejections_total{reason="ip"} 1`)
+}
+
+func TestRateLimitPerUser(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t,
+		config.WithReadAuthorizations(testUserCredentials),
+		config.WithRateLimitUser(0.01, 1),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	get := func() int {
+		req, err := http.NewRequest(http.MethodGet, HTTPAddressStatic+"/", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("user", "password")
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, get())
+	assert.Equal(t, http.StatusTooManyRequests, get())
+}
+
+func TestConcurrentUploadsAreCapped(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t, config.WithMaxConcurrentUploads(1))
+
+	defer s.Shutdown(context.Background())
+
+	// Keep an upload in progress by not finishing its body.
+	pr, pw := io.Pipe()
+	defer pw.Close()
+	slow, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, pr)
+	require.NoError(t, err)
+	slow.Header.Add("Content-Type", "application/octet-stream")
+	slow.Header.Add(DeployPathHeader, "/slow.txt")
+	done := make(chan int)
+	go func() {
+		resp, err := http.DefaultClient.Do(slow)
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	_, err = pw.Write([]byte("started"))
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, sampleTARGZContentReader())
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/tar+gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "1", resp.Header.Get("Retry-After"))
+
+	pw.Close()
+	assert.Equal(t, http.StatusOK, <-done)
+}
@@ -0,0 +1,238 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/server"
+)
+
+func listingOf(t *testing.T, url string) (int, *server.Listing) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth("user", "password")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, nil
+	}
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	listing := &server.Listing{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(listing))
+	return resp.StatusCode, listing
+}
+
+func entryPaths(listing *server.Listing) []string {
+	paths := make([]string, 0, len(listing.Entries))
+	for _, e := range listing.Entries {
+		paths = append(paths, e.Path)
+	}
+	return paths
+}
+
+func TestDirectoryListingJSON(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	status, listing := listingOf(t, HTTPAddressStatic+"/")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "/", listing.Path)
+	assert.Equal(t, 3, listing.Total)
+	assert.Equal(t, []string{"/gnu.png", "/notes", "/tux.png"}, entryPaths(listing))
+
+	notes := listing.Entries[1]
+	assert.Equal(t, "notes", notes.Name)
+	assert.Equal(t, "dir", notes.Type)
+	require.NotNil(t, notes.Children)
+	assert.Equal(t, 2, *notes.Children)
+
+	gnu := listing.Entries[0]
+	assert.Equal(t, "file", gnu.Type)
+	assert.Nil(t, gnu.Children)
+	assert.NotZero(t, gnu.Size)
+	assert.False(t, gnu.ModTime.IsZero())
+}
+
+func TestDirectoryListingJSONIsPaginatedAndSorted(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	_, listing := listingOf(t, HTTPAddressStatic+"/?depth=3&sort=type&order=desc&per_page=3&page=2")
+	assert.Equal(t, 6, listing.Total)
+	assert.False(t, listing.Truncated)
+	assert.Equal(t, 2, listing.Page)
+	assert.Equal(t, 3, listing.PerPage)
+	assert.Equal(t, []string{"/gnu.png", "/notes/subnotes", "/notes"}, entryPaths(listing))
+
+	_, listing = listingOf(t, HTTPAddressStatic+"/?depth=3&per_page=1")
+	assert.True(t, listing.Truncated, "subdirectories are not walked past the requested page")
+	assert.Equal(t, 3, listing.Total)
+
+	_, listing = listingOf(t, HTTPAddressStatic+"/notes?depth=2")
+	assert.Equal(t, []string{"/notes/notes.txt", "/notes/subnotes", "/notes/subnotes/notes.txt"}, entryPaths(listing))
+
+	status, listing := listingOf(t, HTTPAddressStatic+"/?page=9223372036854775807&per_page=2")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 3, listing.Total)
+	assert.Empty(t, listing.Entries)
+
+	status, _ = listingOf(t, HTTPAddressStatic+"/?sort=color")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = listingOf(t, HTTPAddressStatic+"/?per_page=0")
+	assert.Equal(t, http.StatusBadRequest, status)
+}
+
+func TestDirectoryListingJSONHidesHiddenFiles(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	hiddenDocRoot(t, docRoot)
+
+	_, listing := listingOf(t, HTTPAddressStatic+"/?depth=2")
+	assert.NotContains(t, entryPaths(listing), "/.git")
+	assert.NotContains(t, entryPaths(listing), "/notes/.env")
+	assert.Contains(t, entryPaths(listing), "/.well-known/security.txt")
+
+	status, _ := listingOf(t, HTTPAddressStatic+"/.git/")
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestDirectoryListingJSONRequiresReadAuthorization(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithReadAuthorizations(testUserCredentials))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	status, listing := listingOf(t, HTTPAddressStatic+"/")
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, 3, listing.Total)
+
+	req, err := http.NewRequest(http.MethodGet, HTTPAddressStatic+"/", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestDirectoryListingIsHTMLByDefault(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	assert.Contains(t, string(BodyFrom(t, HTTPAddressStatic+"/")), "<a href=\"notes/\">notes/</a>")
+}
+
+func TestDirectoryIndexHTML(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	resp, err := http.Get(HTTPAddressStatic + "/notes/?sort=size&order=desc")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	page := string(body)
+	assert.Contains(t, page, `<a href="/static/">/</a><a href="/static/notes/">notes</a>`)
+	assert.Contains(t, page, `<a href="?sort=size&amp;order=asc">Size</a>`)
+	assert.Contains(t, page, `<a href="?sort=name&amp;order=asc">Name</a>`)
+	assert.Contains(t, page, `<a href="subnotes/">subnotes/</a>`)
+	assert.Less(t, strings.Index(page, ">subnotes/<"), strings.Index(page, ">notes.txt<"))
+}
+
+func TestDirectoryIndexHTMLServesIndexFile(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+	require.NoError(t, os.WriteFile(filepath.Join(docRoot, "notes", "index.html"), []byte("notes index"), 0600))
+
+	assert.Equal(t, "notes index", string(BodyFrom(t, HTTPAddressStatic+"/notes/")))
+}
+
+func TestDirectoryIndexHTMLCustomTemplate(t *testing.T) {
+	BeforeEach(t)
+
+	tmpl := filepath.Join(t.TempDir(), "listing.html")
+	require.NoError(t, os.WriteFile(tmpl, []byte(`{{.Path}}:{{range .Entries}} {{.Name}}{{if eq .Type "file"}}={{size .Size}}{{end}}{{end}}`), 0600))
+
+	s, _, docRoot := sut(t, config.WithListingTemplate(tmpl))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	assert.Equal(t, "/notes/: notes.txt=20 B subnotes", string(BodyFrom(t, HTTPAddressStatic+"/notes/")))
+}
+
+func TestDirectoryIndexHTMLBadTemplateFailsOnStart(t *testing.T) {
+	tmpl := filepath.Join(t.TempDir(), "listing.html")
+	require.NoError(t, os.WriteFile(tmpl, []byte(`{{.Path`), 0600))
+
+	_, err := server.New(config.ForOptions(config.WithDocRoot(t.TempDir()), config.WithListingTemplate(tmpl)))
+	assert.Error(t, err)
+}
+
+func TestDirectoryListingsCanBeDisabled(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithListingDisabled("/notes/**"))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	assert.Equal(t, http.StatusOK, statusOf(t, HTTPAddressStatic+"/"))
+	assert.Equal(t, http.StatusOK, statusOf(t, HTTPAddressStatic+"/notes/"))
+	assert.Equal(t, http.StatusForbidden, statusOf(t, HTTPAddressStatic+"/notes/subnotes/"))
+	assert.Equal(t, http.StatusOK, statusOf(t, HTTPAddressStatic+"/notes/subnotes/notes.txt"))
+
+	status, _ := listingOf(t, HTTPAddressStatic+"/notes/subnotes/")
+	assert.Equal(t, http.StatusForbidden, status)
+}
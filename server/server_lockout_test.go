@@ -0,0 +1,56 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func TestBasicAuthLockout(t *testing.T) {
+	BeforeEach(t)
+
+	s, logs, _ := sut(t,
+		config.WithReadAuthorizations(testUserCredentials),
+		config.WithAuthLockout(3, time.Minute, time.Hour),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	get := func(password string) *http.Response {
+		req, err := http.NewRequest(http.MethodGet, HTTPAddressStatic+"/", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("user", password)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp
+	}
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusUnauthorized, get("bad-password").StatusCode)
+	}
+	// Even the right password is rejected while locked out.
+	resp := get("password")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, "60", resp.Header.Get("Retry-After"))
+
+	resp, err := http.Get(HTTPAddress + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `http_auth_failures_total{mechanism="basic"} 3`)
+	assert.Contains(t, string(data), `http_rate_limit_rejections_total{reason="lockout"} 1`)
+
+	s.Shutdown(context.Background()) // Force shutdown here in order to avoid data race with the logger buffer
+	assert.Contains(t, logs.String(), "authentication lockout triggered for user:user during 1m0s")
+	assert.Contains(t, logs.String(), "authentication lockout triggered for ip:127.0.0.1 during 1m0s")
+}
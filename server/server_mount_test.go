@@ -0,0 +1,145 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/server"
+)
+
+func mountUpload(t *testing.T, url, deployPath, content string, credentials ...string) int {
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(content))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set(DeployPathHeader, deployPath)
+	if len(credentials) == 2 {
+		req.SetBasicAuth(credentials[0], credentials[1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestMounts(t *testing.T) {
+	BeforeEach(t)
+
+	releases := t.TempDir()
+	test.Copy(t, DocRoot, releases)
+	require.NoError(t, os.WriteFile(filepath.Join(releases, "v1.0.0.txt"), []byte("v1.0.0"), 0600))
+
+	s, _, docRoot := sut(t, config.WithMounts(&config.Mount{
+		Name:            "releases",
+		Prefix:          "/releases",
+		Dir:             releases,
+		ReadOnly:        true,
+		ListingDisabled: []string{"notes/**"},
+	}))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	assert.Equal(t, "v1.0.0", string(BodyFrom(t, HTTPAddress+"/releases/v1.0.0.txt")))
+	assert.Equal(t, http.StatusNotFound, statusOf(t, HTTPAddressStatic+"/v1.0.0.txt"))
+	assert.Equal(t, http.StatusOK, statusOf(t, HTTPAddressStatic+"/notes/notes.txt"))
+
+	status, listing := listingOf(t, HTTPAddress+"/releases/")
+	require.Equal(t, http.StatusOK, status)
+	assert.Contains(t, entryPaths(listing), "/v1.0.0.txt")
+	status, _ = listingOf(t, HTTPAddress+"/releases/notes/subnotes/")
+	assert.Equal(t, http.StatusForbidden, status)
+	status, _ = listingOf(t, HTTPAddressStatic+"/notes/subnotes/")
+	assert.Equal(t, http.StatusOK, status)
+
+	// Read only mounts do not accept uploads.
+	assert.Equal(t, http.StatusNotFound, mountUpload(t, HTTPAddress+"/releases", "/v2.0.0.txt", "v2.0.0"))
+}
+
+func TestWritableMountsHaveTheirOwnAuthorizations(t *testing.T) {
+	BeforeEach(t)
+
+	releases := t.TempDir()
+
+	s, _, _ := sut(t,
+		config.WithReadAuthorizations(testUserCredentials),
+		config.WithMounts(&config.Mount{
+			Name:                "releases",
+			Prefix:              "/releases",
+			Dir:                 releases,
+			WriteAuthorizations: testUserCredentials,
+		}),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	assert.Equal(t, http.StatusUnauthorized, mountUpload(t, HTTPAddress+"/releases", "/v2.0.0.txt", "v2.0.0"))
+	assert.Equal(t, http.StatusOK, mountUpload(t, HTTPAddress+"/releases", "/v2.0.0.txt", "v2.0.0", "user", "password"))
+
+	content, err := os.ReadFile(filepath.Join(releases, "v2.0.0.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "v2.0.0", string(content))
+
+	// The mount inherits the read authorizations of the document root.
+	assert.Equal(t, http.StatusUnauthorized, statusOf(t, HTTPAddress+"/releases/v2.0.0.txt"))
+	req, err := http.NewRequest(http.MethodGet, HTTPAddress+"/releases/v2.0.0.txt", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("user", "password")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestWritableMountsInheritTheWriteAuthorizations(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t,
+		config.WithWriteAuthorizations(testUserCredentials),
+		config.WithMounts(&config.Mount{Name: "releases", Prefix: "/releases", Dir: t.TempDir()}),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	assert.Equal(t, http.StatusUnauthorized, mountUpload(t, HTTPAddress+"/releases", "/v2.0.0.txt", "v2.0.0"))
+	assert.Equal(t, http.StatusOK, mountUpload(t, HTTPAddress+"/releases", "/v2.0.0.txt", "v2.0.0", "user", "password"))
+}
+
+func TestWritableMountsWithoutAuthenticationFailOnStart(t *testing.T) {
+	BeforeEach(t)
+
+	mount := &config.Mount{Name: "releases", Prefix: "/releases", Dir: t.TempDir()}
+	_, err := server.New(config.ForOptions(config.WithDocRoot(t.TempDir()), config.WithMounts(mount)))
+	assert.Error(t, err)
+
+	_, err = server.New(config.ForOptions(
+		config.WithDocRoot(t.TempDir()),
+		config.WithTokensFile(filepath.Join(t.TempDir(), "tokens.json")),
+		config.WithMounts(mount),
+	))
+	assert.NoError(t, err)
+}
+
+func TestMountsCannotOverlap(t *testing.T) {
+	dir := t.TempDir()
+	cases := []*config.Mount{
+		{Name: "a", Prefix: "/static/releases", Dir: dir},
+		{Name: "b", Prefix: "/releases/", Dir: dir},
+		{Name: "c", Prefix: "/releases", Dir: filepath.Join(dir, "missing")},
+	}
+	for _, m := range cases {
+		_, err := server.New(config.ForOptions(config.WithDocRoot(t.TempDir()), config.WithMounts(m)))
+		assert.Error(t, err, m.Name)
+	}
+}
@@ -0,0 +1,99 @@
+//+build integration
+
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/upload"
+)
+
+func uploadPolicy(t *testing.T, value string) config.UploadPolicy {
+	var p config.UploadPolicy
+	require.NoError(t, p.Decode(value))
+	return p
+}
+
+func TestUploadPolicyRejectsWholeArchive(t *testing.T) {
+	BeforeEach(t)
+
+	stagingDir := t.TempDir()
+	s, _, docRoot := sut(t,
+		config.WithUploadPolicy(uploadPolicy(t, "/ deny_ext=.html; /v1 allow_ext=.txt")),
+		config.WithUploadStagingDir(stagingDir),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, sampleTARGZContentReader())
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/tar+gzip")
+	req.Header.Add(DeployPathHeader, "/v1")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	var body struct {
+		Error      string             `json:"error"`
+		Violations []upload.Violation `json:"violations"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "upload policy violation", body.Error)
+	assert.ElementsMatch(t, []upload.Violation{
+		{Path: "/v1/gnu.png", Reason: `extension ".png" is not allowed`},
+		{Path: "/v1/tux.png", Reason: `extension ".png" is not allowed`},
+	}, body.Violations)
+
+	assert.NoDirExists(t, filepath.Join(docRoot, "v1"))
+	staged, err := os.ReadDir(stagingDir)
+	require.NoError(t, err)
+	assert.Empty(t, staged)
+}
+
+func TestUploadPolicySniffsSingleFiles(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithUploadPolicy(uploadPolicy(t, "/images allow_mime=image/*")))
+
+	defer s.Shutdown(context.Background())
+
+	cases := []struct {
+		path    string
+		content []byte
+		status  int
+	}{
+		{"/images/fake.png", []byte("<html><script>alert(1)</script></html>"), http.StatusUnsupportedMediaType},
+		{"/images/gnu.png", readFile(t, filepath.Join(DocRoot, "gnu.png")), http.StatusOK},
+		{"/notes/page.html", []byte("<html></html>"), http.StatusOK},
+	}
+	for _, c := range cases {
+		req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, bytes.NewReader(c.content))
+		require.NoError(t, err)
+		req.Header.Add("Content-Type", "application/octet-stream")
+		req.Header.Add(DeployPathHeader, c.path)
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, c.status, resp.StatusCode, c.path)
+	}
+	assert.NoFileExists(t, filepath.Join(docRoot, "images", "fake.png"))
+	assert.FileExists(t, filepath.Join(docRoot, "images", "gnu.png"))
+	assert.FileExists(t, filepath.Join(docRoot, "notes", "page.html"))
+}
+
+func readFile(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	return data
+}
@@ -0,0 +1,107 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func sidecarsDocRoot(t *testing.T, docRoot string) {
+	for name, content := range map[string]string{
+		"app.js":    "console.log('identity')",
+		"app.js.br": "brotli content",
+		"app.js.gz": "gzip content",
+		"data":      "{\"plain\": true}",
+		"data.gz":   "gzip data",
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(docRoot, name), []byte(content), 0600))
+	}
+}
+
+func precompressedGet(t *testing.T, url, acceptEncoding string, headers ...string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestPrecompressedSidecarsAreServed(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithPrecompressed(true))
+
+	defer s.Shutdown(context.Background())
+
+	sidecarsDocRoot(t, docRoot)
+
+	resp, body := precompressedGet(t, HTTPAddressStatic+"/app.js", "gzip, br")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "brotli content", body)
+	assert.Equal(t, "br", resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "text/javascript; charset=utf-8", resp.Header.Get("Content-Type"))
+	assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+
+	resp, body = precompressedGet(t, HTTPAddressStatic+"/app.js", "gzip, br;q=0.5")
+	assert.Equal(t, "gzip content", body)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+	resp, body = precompressedGet(t, HTTPAddressStatic+"/app.js", "identity")
+	assert.Equal(t, "console.log('identity')", body)
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", resp.Header.Get("Vary"))
+
+	resp, body = precompressedGet(t, HTTPAddressStatic+"/data", "gzip")
+	assert.Equal(t, "gzip data", body)
+	assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+}
+
+func TestPrecompressedSidecarsSupportRangesAndConditionals(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithPrecompressed(true))
+
+	defer s.Shutdown(context.Background())
+
+	sidecarsDocRoot(t, docRoot)
+
+	resp, body := precompressedGet(t, HTTPAddressStatic+"/app.js", "br", "Range", "bytes=0-5")
+	assert.Equal(t, http.StatusPartialContent, resp.StatusCode)
+	assert.Equal(t, "brotli", body)
+	assert.Equal(t, "bytes 0-5/14", resp.Header.Get("Content-Range"))
+
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/app.js", "br")
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/app.js", "br", "If-Modified-Since", resp.Header.Get("Last-Modified"))
+	assert.Equal(t, http.StatusNotModified, resp.StatusCode)
+}
+
+func TestPrecompressedSidecarsAreDisabledByDefault(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	sidecarsDocRoot(t, docRoot)
+
+	resp, body := precompressedGet(t, HTTPAddressStatic+"/app.js", "br")
+	assert.Equal(t, "console.log('identity')", body)
+	assert.Empty(t, resp.Header.Get("Content-Encoding"))
+}
@@ -0,0 +1,131 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/auth"
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/server"
+)
+
+func TestRedirectRules(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithRedirects(`
+		/old-notes/** /notes/$1 308
+		/tmp/*.txt /notes/${1}.txt 302
+		/external https://example.com/
+		~^/latest/(.*)$ /notes/subnotes/$1 200
+	`, false))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	resp, _ := precompressedGet(t, HTTPAddressStatic+"/old-notes/subnotes/notes.txt?v=1", "")
+	assert.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+	assert.Equal(t, "/static/notes/subnotes/notes.txt?v=1", resp.Header.Get("Location"))
+
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/tmp/notes.txt", "")
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/static/notes/notes.txt", resp.Header.Get("Location"))
+
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/external", "")
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, "https://example.com/", resp.Header.Get("Location"))
+
+	expected, err := os.ReadFile(filepath.Join(DocRoot, "notes", "subnotes", "notes.txt"))
+	require.NoError(t, err)
+	resp, body := precompressedGet(t, HTTPAddressStatic+"/latest/notes.txt", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, string(expected), body)
+
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/notes/notes.txt", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	metrics := string(BodyFrom(t, HTTPAddress+"/metrics"))
+	assert.Contains(t, metrics, `http_redirect_rule_hits_total{rule="/old-notes/**",status="308"} 1`)
+	assert.Contains(t, metrics, `http_redirect_rule_hits_total{rule="~^/latest/(.*)$",status="200"} 1`)
+}
+
+func TestRedirectRulesFromFile(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithRedirects("/gnu.png /tux.png 307", true))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	resp, _ := precompressedGet(t, HTTPAddressStatic+"/old.txt", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	require.NoError(t, os.WriteFile(filepath.Join(docRoot, "_redirects"), []byte("/old.txt /notes/notes.txt 301\n/gnu.png /notes/ 301\n"), 0600))
+
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/old.txt", "")
+	assert.Equal(t, http.StatusMovedPermanently, resp.StatusCode)
+	assert.Equal(t, "/static/notes/notes.txt", resp.Header.Get("Location"))
+
+	// Configured rules take precedence over the file ones.
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/gnu.png", "")
+	assert.Equal(t, http.StatusTemporaryRedirect, resp.StatusCode)
+	assert.Equal(t, "/static/tux.png", resp.Header.Get("Location"))
+
+	// The rules file itself is not served.
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/_redirects", "")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestRedirectRulesInvalidStatusFailsOnStart(t *testing.T) {
+	_, err := server.New(config.ForOptions(config.WithDocRoot(t.TempDir()), config.WithRedirects("/a /b 303", false)))
+	assert.Error(t, err)
+}
+
+func TestRewritesAreCheckedAgainstTheRequestAuthorization(t *testing.T) {
+	BeforeEach(t)
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens.json")
+	secret, _, err := auth.NewTokenStore(tokensFile).Create("reader", []auth.Scope{"read:/notes"}, 0)
+	require.NoError(t, err)
+
+	s, _, docRoot := sut(t,
+		config.WithTokensFile(tokensFile),
+		config.WithReadAuthorizations(testUserCredentials),
+		config.WithURLSigningKey(testURLSigningKey),
+		config.WithRedirects(`
+			/notes/tux.png /tux.png 200
+			/notes/alias.txt /notes/notes.txt 200
+		`, false),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	resp, _ := precompressedGet(t, HTTPAddressStatic+"/notes/tux.png", "", "Authorization", "Bearer "+secret)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "rewrite targets out of the token scopes are forbidden")
+	resp, _ = precompressedGet(t, HTTPAddressStatic+"/notes/alias.txt", "", "Authorization", "Bearer "+secret)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, _ = precompressedGet(t, signURL(t, "/static/notes/alias.txt", auth.SignOptions{Expires: time.Now().Add(time.Hour)}), "")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode, "signatures do not cover rewrite targets")
+
+	req, err := http.NewRequest(http.MethodGet, HTTPAddressStatic+"/notes/tux.png", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("user", "password")
+	basic, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer basic.Body.Close()
+	assert.Equal(t, http.StatusOK, basic.StatusCode)
+}
@@ -0,0 +1,131 @@
+//+build integration
+
+package server_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/upload"
+)
+
+// clamdStandIn starts a minimal clamd compatible server, that flags
+// as infected any stream containing the EICAR test string.
+func clamdStandIn(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				if _, err := r.ReadString(0); err != nil {
+					return
+				}
+				var data bytes.Buffer
+				for {
+					var size uint32
+					if err := binary.Read(r, binary.BigEndian, &size); err != nil || size == 0 {
+						break
+					}
+					if _, err := io.CopyN(&data, r, int64(size)); err != nil {
+						return
+					}
+				}
+				if strings.Contains(data.String(), "EICAR-STANDARD-ANTIVIRUS-TEST-FILE") {
+					_, _ = conn.Write([]byte("stream: Eicar-Signature FOUND\x00"))
+					return
+				}
+				_, _ = conn.Write([]byte("stream: OK\x00"))
+			}()
+		}
+	}()
+	return "tcp://" + l.Addr().String()
+}
+
+func uploadContent(t *testing.T, deployPath string, content []byte) *http.Response {
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, bytes.NewReader(content))
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/octet-stream")
+	req.Header.Add(DeployPathHeader, deployPath)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	return resp
+}
+
+func TestUploadsAreScanned(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithUploadScanner(clamdStandIn(t), time.Second, false))
+
+	defer s.Shutdown(context.Background())
+
+	resp := uploadContent(t, "/eicar.txt", []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`))
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnprocessableEntity, resp.StatusCode)
+	var body struct {
+		Error      string             `json:"error"`
+		Infections []upload.Infection `json:"infections"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+	assert.Equal(t, "malware detected", body.Error)
+	assert.Equal(t, []upload.Infection{{Path: "/eicar.txt", Signature: "Eicar-Signature"}}, body.Infections)
+	assert.NoFileExists(t, filepath.Join(docRoot, "eicar.txt"))
+
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, sampleTARGZContentReader())
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/tar+gzip")
+	req.Header.Add(DeployPathHeader, "/v1")
+	resp2, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	assert.Equal(t, http.StatusOK, resp2.StatusCode)
+	assert.FileExists(t, filepath.Join(docRoot, "v1", "notes", "notes.txt"))
+
+	metrics := string(BodyFrom(t, HTTPAddress+"/metrics"))
+	assert.Contains(t, metrics, `http_upload_scans_total{result="clean"} 4`)
+	assert.Contains(t, metrics, `http_upload_scans_total{result="infected"} 1`)
+}
+
+func TestUploadScanFailures(t *testing.T) {
+	cases := []struct {
+		name     string
+		failOpen bool
+		status   int
+	}{
+		{"fail closed", false, http.StatusServiceUnavailable},
+		{"fail open", true, http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			BeforeEach(t)
+
+			s, _, _ := sut(t, config.WithUploadScanner("tcp://127.0.0.1:1", time.Second, c.failOpen))
+
+			defer s.Shutdown(context.Background())
+
+			resp := uploadContent(t, "/notes.txt", []byte("notes"))
+			defer resp.Body.Close()
+			assert.Equal(t, c.status, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,163 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/auth"
+	"go.eloylp.dev/go-serve/config"
+)
+
+const testURLSigningKey = "test-signing-key"
+
+func signURL(t *testing.T, target string, opts auth.SignOptions) string {
+	signed, err := auth.NewSigner([]byte(testURLSigningKey)).Sign(target, opts)
+	require.NoError(t, err)
+	return HTTPAddress + signed
+}
+
+func TestSignedURLIsAcceptedInLieuOfBasicAuth(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t,
+		config.WithReadAuthorizations(testUserCredentials),
+		config.WithURLSigningKey(testURLSigningKey),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	resp, err := http.Get(signURL(t, "/static/tux.png", auth.SignOptions{Expires: time.Now().Add(time.Hour)}))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The signature is bound to the signed path.
+	resp, err = http.Get(signURL(t, "/static/tux.png", auth.SignOptions{Expires: time.Now().Add(time.Hour)}) + "&path=/gnu.png")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSignedURLExpired(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t,
+		config.WithReadAuthorizations(testUserCredentials),
+		config.WithURLSigningKey(testURLSigningKey),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	resp, err := http.Get(signURL(t, "/static/tux.png", auth.SignOptions{Expires: time.Now().Add(-time.Minute)}))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSignedURLSingleUseAndIPBinding(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t,
+		config.WithReadAuthorizations(testUserCredentials),
+		config.WithURLSigningKey(testURLSigningKey),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	signed := signURL(t, "/static/tux.png", auth.SignOptions{Expires: time.Now().Add(time.Hour), IP: "127.0.0.1", SingleUse: true})
+	resp, err := http.Get(signed)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(signed)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp, err = http.Get(signURL(t, "/static/tux.png", auth.SignOptions{Expires: time.Now().Add(time.Hour), IP: "10.0.0.1"}))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestSignedURLSingleUseIsOnlyConsumedBySuccessfulResponses(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t,
+		config.WithReadAuthorizations(testUserCredentials),
+		config.WithURLSigningKey(testURLSigningKey),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	signed := signURL(t, "/static/notes/notes.txt", auth.SignOptions{Expires: time.Now().Add(time.Hour), SingleUse: true})
+	assert.Equal(t, http.StatusNotFound, statusOf(t, signed))
+
+	test.Copy(t, DocRoot, docRoot)
+	assert.Equal(t, http.StatusOK, statusOf(t, signed))
+	assert.Equal(t, http.StatusUnauthorized, statusOf(t, signed))
+}
+
+func TestSignedDownloadURL(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t,
+		config.WithReadAuthorizations(testUserCredentials),
+		config.WithURLSigningKey(testURLSigningKey),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	req, err := http.NewRequest(http.MethodGet, signURL(t, "/download?path=/notes", auth.SignOptions{Expires: time.Now().Add(time.Hour)}), nil)
+	require.NoError(t, err)
+	req.Header.Add("Accept", "application/tar+gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	AssertTARGZMD5Sums(t, resp.Body, map[string]string{
+		".":                  "",
+		"notes.txt":          NotesTestFileMD5,
+		"subnotes":           "",
+		"subnotes/notes.txt": SubNotesTestFileMD5,
+	})
+}
+
+func TestSignedDownloadURLRejectsDownloadPathHeader(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t,
+		config.WithReadAuthorizations(testUserCredentials),
+		config.WithURLSigningKey(testURLSigningKey),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	req, err := http.NewRequest(http.MethodGet, signURL(t, "/download?path=/notes", auth.SignOptions{Expires: time.Now().Add(time.Hour)}), nil)
+	require.NoError(t, err)
+	req.Header.Add("Accept", "application/tar+gzip")
+	req.Header.Add(DownloadPathHeader, "/")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
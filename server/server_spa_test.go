@@ -0,0 +1,69 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func spaDocRoot(t *testing.T, docRoot string) {
+	for dir, index := range map[string]string{"app": "app index", "app/admin": "admin index"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(docRoot, dir, "assets"), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(docRoot, dir, "index.html"), []byte(index), 0600))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(docRoot, "app", "assets", "main.js"), []byte("main"), 0600))
+}
+
+func TestSPAFallbackServesIndex(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithSPAPrefixes("/app", "/app/admin/"))
+
+	defer s.Shutdown(context.Background())
+
+	spaDocRoot(t, docRoot)
+
+	resp, err := http.Get(HTTPAddressStatic + "/app/settings/profile")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	assert.Equal(t, "app index", string(BodyFrom(t, HTTPAddressStatic+"/app/settings")))
+	assert.Equal(t, "admin index", string(BodyFrom(t, HTTPAddressStatic+"/app/admin/users")))
+	assert.Equal(t, "main", string(BodyFrom(t, HTTPAddressStatic+"/app/assets/main.js")))
+}
+
+func TestSPAFallbackKeepsNotFoundAssets(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithSPAPrefixes("/app"))
+
+	defer s.Shutdown(context.Background())
+
+	spaDocRoot(t, docRoot)
+
+	assert.Equal(t, http.StatusNotFound, statusOf(t, HTTPAddressStatic+"/app/assets/missing.js"))
+	assert.Equal(t, http.StatusNotFound, statusOf(t, HTTPAddressStatic+"/other/settings"))
+}
+
+func TestSPAFallbackIsDisabledByDefault(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	spaDocRoot(t, docRoot)
+
+	assert.Equal(t, http.StatusNotFound, statusOf(t, HTTPAddressStatic+"/app/settings"))
+}
@@ -0,0 +1,133 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/server"
+	"go.eloylp.dev/go-serve/storage"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t, config.WithStorage(storage.NewMemory()))
+
+	defer s.Shutdown(context.Background())
+
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, sampleTARGZContentReader())
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", server.ContentTypeTarGzip)
+	req.Header.Add(DeployPathHeader, "/sub-root")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, http.StatusOK, mountUpload(t, HTTPAddressUpload, "/sub-root/notes/new.txt", "new"))
+
+	tux := BodyFrom(t, HTTPAddressStatic+"/sub-root/tux.png")
+	assert.Equal(t, TuxTestFileMD5, md5From(tux))
+	assert.Equal(t, "new", string(BodyFrom(t, HTTPAddressStatic+"/sub-root/notes/new.txt")))
+
+	status, listing := listingOf(t, HTTPAddressStatic+"/sub-root/notes/")
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, []string{"/sub-root/notes/new.txt", "/sub-root/notes/notes.txt", "/sub-root/notes/subnotes"}, entryPaths(listing))
+
+	req, err = http.NewRequest(http.MethodGet, HTTPAddressDownload, nil)
+	require.NoError(t, err)
+	req.Header.Add("Accept", server.ContentTypeTarGzip)
+	req.Header.Add(DownloadPathHeader, "/sub-root/notes/subnotes")
+	download, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer download.Body.Close()
+	require.Equal(t, http.StatusOK, download.StatusCode)
+	AssertTARGZMD5Sums(t, download.Body, map[string]string{
+		".":         "",
+		"notes.txt": SubNotesTestFileMD5,
+	})
+
+	entries, err := os.ReadDir(docRoot)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "nothing must be written to the document root directory")
+}
+
+func TestMemoryStorageRedirectsFile(t *testing.T) {
+	BeforeEach(t)
+
+	store := storage.NewMemory()
+	w, err := store.Create("/_redirects", 0644)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, "/old.txt /new.txt 302")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	s, _, _ := sut(t, config.WithStorage(store), config.WithRedirects("", true))
+
+	defer s.Shutdown(context.Background())
+
+	resp, _ := precompressedGet(t, HTTPAddressStatic+"/old.txt", "")
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/static/new.txt", resp.Header.Get("Location"))
+	assert.Equal(t, http.StatusNotFound, statusOf(t, HTTPAddressStatic+"/_redirects"))
+}
+
+func TestArchivesNeedLocalStorage(t *testing.T) {
+	_, err := server.New(config.ForOptions(
+		config.WithDocRoot(t.TempDir()),
+		config.WithStorage(storage.NewMemory()),
+		config.WithArchives(true, 1<<20),
+	))
+	assert.Error(t, err)
+}
+
+func TestLocalStorageSymlinksPolicy(t *testing.T) {
+	BeforeEach(t)
+
+	root := t.TempDir()
+	s, _, _ := sut(t, config.WithStorage(storage.NewLocal(root)))
+
+	defer s.Shutdown(context.Background())
+
+	outside := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0600))
+	test.Copy(t, DocRoot, root)
+	require.NoError(t, os.Symlink("notes", filepath.Join(root, "alias")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "outside")))
+
+	assert.Equal(t, http.StatusOK, statusOf(t, HTTPAddressStatic+"/alias/notes.txt"))
+	assert.Equal(t, http.StatusNotFound, statusOf(t, HTTPAddressStatic+"/outside/secret.txt"))
+
+	req, err := http.NewRequest(http.MethodGet, HTTPAddressDownload, nil)
+	require.NoError(t, err)
+	req.Header.Add("Accept", server.ContentTypeTarGzip)
+	req.Header.Add(DownloadPathHeader, "/outside")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	upload := uploadContent(t, "/outside/evil.txt", []byte("evil"))
+	defer upload.Body.Close()
+	assert.Equal(t, http.StatusForbidden, upload.StatusCode)
+	assert.NoFileExists(t, filepath.Join(outside, "evil.txt"))
+}
+
+func TestSymlinksPolicyNeedsLocalStorage(t *testing.T) {
+	_, err := server.New(config.ForOptions(
+		config.WithDocRoot(t.TempDir()),
+		config.WithStorage(storage.NewMemory()),
+		config.WithSymlinks("allow"),
+	))
+	assert.Error(t, err)
+}
@@ -0,0 +1,100 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/config"
+)
+
+func statusOf(t *testing.T, url string) int {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSymlinksPolicy(t *testing.T) {
+	cases := []struct {
+		policy  string
+		alias   int
+		outside int
+	}{
+		{"deny", http.StatusNotFound, http.StatusNotFound},
+		{"inside", http.StatusOK, http.StatusNotFound},
+		{"allow", http.StatusOK, http.StatusOK},
+	}
+	for _, c := range cases {
+		t.Run(c.policy, func(t *testing.T) {
+			BeforeEach(t)
+
+			s, _, docRoot := sut(t, config.WithSymlinks(c.policy))
+
+			defer s.Shutdown(context.Background())
+
+			outside := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0600))
+			test.Copy(t, DocRoot, docRoot)
+			require.NoError(t, os.Symlink("notes", filepath.Join(docRoot, "alias")))
+			require.NoError(t, os.Symlink(outside, filepath.Join(docRoot, "outside")))
+
+			assert.Equal(t, c.alias, statusOf(t, HTTPAddressStatic+"/alias/notes.txt"))
+			assert.Equal(t, c.outside, statusOf(t, HTTPAddressStatic+"/outside/secret.txt"))
+		})
+	}
+}
+
+func TestSymlinksOutsideAreHidden(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, docRoot := sut(t)
+
+	defer s.Shutdown(context.Background())
+
+	outside := t.TempDir()
+	test.Copy(t, DocRoot, docRoot)
+	require.NoError(t, os.Symlink(outside, filepath.Join(docRoot, "outside")))
+	require.NoError(t, os.Symlink("notes", filepath.Join(docRoot, "alias")))
+
+	listing := string(BodyFrom(t, HTTPAddressStatic+"/"))
+	assert.Contains(t, listing, "alias")
+	assert.NotContains(t, listing, "outside")
+
+	req, err := http.NewRequest(http.MethodGet, HTTPAddressDownload, nil)
+	require.NoError(t, err)
+	req.Header.Add("Accept", "application/tar+gzip")
+	req.Header.Add(DownloadPathHeader, "/")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	AssertTARGZMD5Sums(t, resp.Body, map[string]string{
+		".":                        "",
+		"alias":                    "d41d8cd98f00b204e9800998ecf8427e",
+		"gnu.png":                  GnuTestFileMD5,
+		"tux.png":                  TuxTestFileMD5,
+		"notes":                    "",
+		"notes/notes.txt":          NotesTestFileMD5,
+		"notes/subnotes":           "",
+		"notes/subnotes/notes.txt": SubNotesTestFileMD5,
+	})
+
+	req.Header.Set(DownloadPathHeader, "/outside")
+	resp2, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp2.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp2.StatusCode)
+
+	upload := uploadContent(t, "/outside/evil.txt", []byte("evil"))
+	defer upload.Body.Close()
+	assert.Equal(t, http.StatusForbidden, upload.StatusCode)
+	assert.NoFileExists(t, filepath.Join(outside, "evil.txt"))
+}
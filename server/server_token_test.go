@@ -0,0 +1,125 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/auth"
+	"go.eloylp.dev/go-serve/config"
+)
+
+func TestTokenUploadInScopeIsAccepted(t *testing.T) {
+	BeforeEach(t)
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens.json")
+	secret, _, err := auth.NewTokenStore(tokensFile).Create("ci", []auth.Scope{"upload:/releases/*"}, time.Hour)
+	require.NoError(t, err)
+
+	s, _, _ := sut(t, config.WithTokensFile(tokensFile))
+
+	defer s.Shutdown(context.Background())
+
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, sampleTARGZContentReader())
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/tar+gzip")
+	req.Header.Add(DeployPathHeader, "/releases/v1.0.0")
+	req.Header.Add("Authorization", "Bearer "+secret)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+func TestTokenUploadOutOfScopeIsRefused(t *testing.T) {
+	BeforeEach(t)
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens.json")
+	secret, _, err := auth.NewTokenStore(tokensFile).Create("ci", []auth.Scope{"upload:/releases/*"}, time.Hour)
+	require.NoError(t, err)
+
+	s, logs, _ := sut(t, config.WithTokensFile(tokensFile))
+
+	defer s.Shutdown(context.Background())
+
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, sampleTARGZContentReader())
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/tar+gzip")
+	req.Header.Add(DeployPathHeader, "/other")
+	req.Header.Add("Authorization", "Bearer "+secret)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	s.Shutdown(context.Background()) // Force shutdown here in order to avoid data race with the logger buffer
+	assert.Contains(t, logs.String(), auth.ErrTokenScope.Error())
+}
+
+func TestTokensRequiredForUploadsWhenEnabled(t *testing.T) {
+	BeforeEach(t)
+
+	s, _, _ := sut(t, config.WithTokensFile(filepath.Join(t.TempDir(), "tokens.json")))
+
+	defer s.Shutdown(context.Background())
+
+	req, err := http.NewRequest(http.MethodPost, HTTPAddressUpload, sampleTARGZContentReader())
+	require.NoError(t, err)
+	req.Header.Add("Content-Type", "application/tar+gzip")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestTokenReadCoexistsWithBasicAuth(t *testing.T) {
+	BeforeEach(t)
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens.json")
+	store := auth.NewTokenStore(tokensFile)
+
+	s, _, docRoot := sut(t,
+		config.WithTokensFile(tokensFile),
+		config.WithReadAuthorizations(testUserCredentials),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	// Tokens created after the server started are also accepted.
+	secret, _, err := store.Create("reader", []auth.Scope{"read:/notes"}, 0)
+	require.NoError(t, err)
+
+	req, err := http.NewRequest(http.MethodGet, HTTPAddressStatic+"/notes/notes.txt", nil)
+	require.NoError(t, err)
+	req.Header.Add("Authorization", "Bearer "+secret)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	req, err = http.NewRequest(http.MethodGet, HTTPAddressStatic+"/tux.png", nil)
+	require.NoError(t, err)
+	req.Header.Add("Authorization", "Bearer "+secret)
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	req, err = http.NewRequest(http.MethodGet, HTTPAddressStatic+"/tux.png", nil)
+	require.NoError(t, err)
+	req.SetBasicAuth("user", "password")
+	resp, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
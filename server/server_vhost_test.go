@@ -0,0 +1,214 @@
+//+build integration
+
+package server_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.eloylp.dev/kit/test"
+
+	"go.eloylp.dev/go-serve/auth"
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/server"
+)
+
+func hostGet(t *testing.T, host, url string, credentials ...string) (*http.Response, string) {
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	require.NoError(t, err)
+	req.Host = host
+	if len(credentials) == 2 {
+		req.SetBasicAuth(credentials[0], credentials[1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(body)
+}
+
+func TestVirtualHosts(t *testing.T) {
+	BeforeEach(t)
+
+	docsRoot := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(docsRoot, "index.txt"), []byte("docs"), 0600))
+	artifactsRoot := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(artifactsRoot, "index.txt"), []byte("artifacts"), 0600))
+
+	headerRules := config.HeaderRules{}
+	require.NoError(t, headerRules.Decode("/** set Cache-Control: no-cache"))
+
+	s, _, docRoot := sut(t, config.WithVirtualHosts(
+		&config.VirtualHost{
+			Name:        "docs",
+			Hosts:       []string{"docs.example", "www.docs.example"},
+			DocRoot:     docsRoot,
+			Prefix:      "/docs",
+			HeaderRules: headerRules,
+		},
+		&config.VirtualHost{
+			Name:               "artifacts",
+			Hosts:              []string{"artifacts.example"},
+			DocRoot:            artifactsRoot,
+			Prefix:             "/files",
+			ReadAuthorizations: testUserCredentials,
+		},
+	))
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	resp, body := hostGet(t, "docs.example", HTTPAddress+"/docs/index.txt")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "docs", body)
+	assert.Equal(t, "no-cache", resp.Header.Get("Cache-Control"))
+
+	_, body = hostGet(t, "WWW.Docs.Example:8080", HTTPAddress+"/docs/index.txt")
+	assert.Equal(t, "docs", body)
+
+	resp, _ = hostGet(t, "artifacts.example", HTTPAddress+"/files/index.txt")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp, body = hostGet(t, "artifacts.example", HTTPAddress+"/files/index.txt", "user", "password")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "artifacts", body)
+	assert.Empty(t, resp.Header.Get("Cache-Control"))
+
+	// The default host serves any other host.
+	resp, _ = hostGet(t, "other.example", HTTPAddressStatic+"/notes/notes.txt")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = hostGet(t, "other.example", HTTPAddressStatic+"/index.txt")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	resp, _ = hostGet(t, "docs.example", HTTPAddressStatic+"/notes/notes.txt")
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	metrics := string(BodyFrom(t, HTTPAddress+"/metrics"))
+	assert.Contains(t, metrics, `http_request_duration_seconds_count{code="200",endpoint="/docs",host="docs",method="GET"} 2`)
+	assert.Contains(t, metrics, `http_request_duration_seconds_count{code="200",endpoint="/files",host="artifacts",method="GET"} 1`)
+	assert.Contains(t, metrics, `http_request_duration_seconds_count{code="200",endpoint="/static",host="default",method="GET"} 1`)
+	assert.Contains(t, metrics, `http_auth_failures_total{host="artifacts",mechanism="basic"} 1`)
+}
+
+func TestVirtualHostsCannotShareHostNames(t *testing.T) {
+	_, err := server.New(config.ForOptions(
+		config.WithDocRoot(t.TempDir()),
+		config.WithVirtualHosts(
+			&config.VirtualHost{Name: "a", Hosts: []string{"docs.example"}, DocRoot: t.TempDir()},
+			&config.VirtualHost{Name: "b", Hosts: []string{"Docs.Example"}, DocRoot: t.TempDir()},
+		),
+	))
+	assert.Error(t, err)
+}
+
+func TestVirtualHostsShareTheLimits(t *testing.T) {
+	BeforeEach(t)
+
+	docsRoot := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(docsRoot, "index.txt"), []byte("docs"), 0600))
+
+	s, _, docRoot := sut(t,
+		config.WithRateLimitIP(0.01, 2),
+		config.WithVirtualHosts(&config.VirtualHost{
+			Name:    "docs",
+			Hosts:   []string{"docs.example"},
+			DocRoot: docsRoot,
+			Prefix:  "/docs",
+		}),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	resp, _ := hostGet(t, "other.example", HTTPAddressStatic+"/notes/notes.txt")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = hostGet(t, "docs.example", HTTPAddress+"/docs/index.txt")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp, _ = hostGet(t, "www.docs.example", HTTPAddressStatic+"/notes/notes.txt")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	resp, _ = hostGet(t, "docs.example", HTTPAddress+"/docs/index.txt")
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+}
+
+func TestVirtualHostsBindTokensAndSignedURLsToTheirHost(t *testing.T) {
+	BeforeEach(t)
+
+	docsRoot := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(docsRoot, "index.txt"), []byte("docs"), 0600))
+
+	tokensFile := filepath.Join(t.TempDir(), "tokens.json")
+	store := auth.NewTokenStore(tokensFile)
+	defaultToken, _, err := store.Create("default", []auth.Scope{"read:/"}, time.Hour)
+	require.NoError(t, err)
+	docsToken, _, err := store.Create("docs", []auth.Scope{"read@docs:/"}, time.Hour)
+	require.NoError(t, err)
+
+	s, _, docRoot := sut(t,
+		config.WithReadAuthorizations(testUserCredentials),
+		config.WithTokensFile(tokensFile),
+		config.WithURLSigningKey(testURLSigningKey),
+		config.WithVirtualHosts(&config.VirtualHost{
+			Name:               "docs",
+			Hosts:              []string{"docs.example"},
+			DocRoot:            docsRoot,
+			Prefix:             "/docs",
+			ReadAuthorizations: testUserCredentials,
+		}),
+	)
+
+	defer s.Shutdown(context.Background())
+
+	test.Copy(t, DocRoot, docRoot)
+
+	status := func(host, url, token string) int {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		require.NoError(t, err)
+		req.Host = host
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+	expires := time.Now().Add(time.Hour)
+
+	assert.Equal(t, http.StatusOK, status("other.example", HTTPAddressStatic+"/notes/notes.txt", defaultToken))
+	assert.Equal(t, http.StatusUnauthorized, status("docs.example", HTTPAddress+"/docs/index.txt", defaultToken))
+	assert.Equal(t, http.StatusOK, status("docs.example", HTTPAddress+"/docs/index.txt", docsToken))
+	assert.Equal(t, http.StatusUnauthorized, status("other.example", HTTPAddressStatic+"/notes/notes.txt", docsToken))
+
+	assert.Equal(t, http.StatusOK, status("docs.example", signURL(t, "/docs/index.txt", auth.SignOptions{Host: "docs", Expires: expires}), ""))
+	assert.Equal(t, http.StatusUnauthorized, status("docs.example", signURL(t, "/docs/index.txt", auth.SignOptions{Expires: expires}), ""))
+	assert.Equal(t, http.StatusUnauthorized, status("other.example", signURL(t, "/static/notes/notes.txt", auth.SignOptions{Host: "docs", Expires: expires}), ""))
+	assert.Equal(t, http.StatusOK, status("other.example", signURL(t, "/static/notes/notes.txt", auth.SignOptions{Expires: expires}), ""))
+}
+
+func TestVirtualHostsBadNameFailsOnStart(t *testing.T) {
+	_, err := server.New(config.ForOptions(
+		config.WithDocRoot(t.TempDir()),
+		config.WithVirtualHosts(&config.VirtualHost{Name: "docs:v2", Hosts: []string{"docs.example"}, DocRoot: t.TempDir()}),
+	))
+	assert.Error(t, err)
+}
+
+func TestVirtualHostsMissingDocRootFailsOnStart(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file.txt")
+	require.NoError(t, os.WriteFile(file, []byte("file"), 0600))
+	for _, docRoot := range []string{filepath.Join(t.TempDir(), "missing"), file} {
+		_, err := server.New(config.ForOptions(
+			config.WithDocRoot(t.TempDir()),
+			config.WithVirtualHosts(&config.VirtualHost{Name: "docs", Hosts: []string{"docs.example"}, DocRoot: docRoot}),
+		))
+		assert.Error(t, err, docRoot)
+	}
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	stdpath "path"
+	"sort"
+	"strings"
+
+	"go.eloylp.dev/go-serve/digest"
+)
+
+// spaHandler serves single page applications under the prefixes. The
+// GET requests for paths that do not exist and have no extension, like
+// client side routes, are replied with the index.html of the longest
+// matching prefix. Missing assets, with extensions, are still not found.
+func spaHandler(fs http.FileSystem, root string, digests *digest.Cache, prefixes []string, next http.Handler) http.Handler {
+	cleaned := make([]string, 0, len(prefixes))
+	for _, p := range prefixes {
+		cleaned = append(cleaned, stdpath.Clean("/"+strings.TrimSpace(p)))
+	}
+	sort.Slice(cleaned, func(i, j int) bool { return len(cleaned[i]) > len(cleaned[j]) })
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := stdpath.Clean("/" + r.URL.Path)
+		prefix, ok := spaPrefix(cleaned, name)
+		if !ok || r.Method != http.MethodGet || stdpath.Ext(name) != "" || exists(fs, name) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		indexName := stdpath.Join(prefix, "index.html")
+		index, err := fs.Open(indexName)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		defer index.Close()
+		info, err := index.Stat()
+		if err != nil || info.IsDir() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := setETag(w, digests, diskPath(root, indexName), info, index); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		http.ServeContent(w, r, info.Name(), info.ModTime(), index)
+	})
+}
+
+// spaPrefix returns the longest of the sorted prefixes the path is under.
+func spaPrefix(prefixes []string, name string) (string, bool) {
+	for _, p := range prefixes {
+		if p == "/" || name == p || strings.HasPrefix(name, p+"/") {
+			return p, true
+		}
+	}
+	return "", false
+}
+
+func exists(fs http.FileSystem, name string) bool {
+	f, err := fs.Open(name)
+	if err != nil {
+		return false
+	}
+	_ = f.Close()
+	return true
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/sirupsen/logrus"
+
+	"go.eloylp.dev/go-serve/audit"
+	"go.eloylp.dev/go-serve/config"
+	"go.eloylp.dev/go-serve/headers"
+	"go.eloylp.dev/go-serve/metrics"
+)
+
+// defaultHost is the host label of the metrics of
+// the requests not served by a virtual host.
+const defaultHost = "default"
+
+// serverHandler returns the router of the settings or, if there are virtual
+// hosts, a dispatcher of the requests to the router of their host. The
+// metrics of every router are labeled by host in such case, while
+// the limits and the authentication state are shared by all of them.
+func serverHandler(cfg *config.Settings, logger *logrus.Logger, docRoot string, info Info, auditSink audit.Sink) http.Handler {
+	if cfg.MetricsEnabled {
+		metrics.Initialize(cfg)
+	}
+	sh := configureShared(cfg, logger)
+	if len(cfg.VirtualHosts) == 0 {
+		return router(cfg, logger, docRoot, info, auditSink, "", prometheus.DefaultRegisterer, sh)
+	}
+	v := &virtualHosts{
+		hosts:    map[string]http.Handler{},
+		fallback: metricsHost(defaultHost, router(cfg, logger, docRoot, info, auditSink, "", hostRegisterer(defaultHost), sh)),
+	}
+	for _, vh := range cfg.VirtualHosts {
+		logger.Infof("configuring virtual host %s for %v", vh.Name, vh.Hosts)
+		hostDocRoot, _ := filepath.Abs(vh.DocRoot) // Already validated by New.
+		h := metricsHost(vh.Name, router(hostSettings(cfg, vh), logger, hostDocRoot, info, auditSink, vh.Name, hostRegisterer(vh.Name), sh))
+		for _, name := range vh.Hosts {
+			v.hosts[hostName(name)] = h
+		}
+	}
+	return v
+}
+
+// hostSettings returns a copy of the settings, with the ones of
+// the virtual host in place of the default ones. Mounts, like a
+// custom storage, are only used by the default host.
+func hostSettings(cfg *config.Settings, vh *config.VirtualHost) *config.Settings {
+	hostCfg := *cfg
+	hostCfg.DocRoot = vh.DocRoot
+	hostCfg.Prefix = vh.Prefix
+	hostCfg.UploadEndpoint = vh.UploadEndpoint
+	hostCfg.DownloadEndpoint = vh.DownloadEndpoint
+	hostCfg.ReadAuthorizations = vh.ReadAuthorizations
+	hostCfg.WriteAuthorizations = vh.WriteAuthorizations
+	hostCfg.HeaderRules = vh.HeaderRules
+	hostCfg.Mounts = nil
+	hostCfg.Storage = nil
+	return &hostCfg
+}
+
+func hostRegisterer(host string) prometheus.Registerer {
+	return prometheus.WrapRegistererWith(prometheus.Labels{"host": host}, prometheus.DefaultRegisterer)
+}
+
+// metricsHost labels the rest of the metrics observed by the
+// requests, like the upload or rate limit ones, with the host.
+func metricsHost(host string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.ServeHTTP(w, r.WithContext(metrics.WithHost(r.Context(), host)))
+	})
+}
+
+// virtualHosts dispatches the requests to the handler of their
+// host, or to the fallback one if there is none.
+type virtualHosts struct {
+	hosts    map[string]http.Handler
+	fallback http.Handler
+}
+
+func (v *virtualHosts) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h, ok := v.hosts[hostName(r.Host)]; ok {
+		h.ServeHTTP(w, r)
+		return
+	}
+	v.fallback.ServeHTTP(w, r)
+}
+
+// hostName normalizes the host, removing the port and the trailing dot.
+func hostName(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// validateVirtualHosts checks that every virtual host has a name that
+// token scopes can refer to, host names, not claimed by others, an existing
+// document root directory and valid header rules.
+func validateVirtualHosts(hosts config.VirtualHosts) error {
+	seen := map[string]string{}
+	for _, vh := range hosts {
+		if vh.Name == "" || strings.ContainsAny(vh.Name, ":@") {
+			return fmt.Errorf("virtual host name %q must not be empty nor contain : or @", vh.Name)
+		}
+		if len(vh.Hosts) == 0 {
+			return fmt.Errorf("virtual host %s has no host names", vh.Name)
+		}
+		for _, name := range vh.Hosts {
+			name = hostName(name)
+			if other, ok := seen[name]; ok {
+				return fmt.Errorf("host %s is claimed by virtual hosts %s and %s", name, other, vh.Name)
+			}
+			seen[name] = vh.Name
+		}
+		if vh.DocRoot == "" {
+			return fmt.Errorf("virtual host %s has no document root", vh.Name)
+		}
+		docRoot, err := filepath.Abs(vh.DocRoot)
+		if err != nil {
+			return err
+		}
+		if info, err := os.Stat(docRoot); err != nil || !info.IsDir() {
+			return fmt.Errorf("virtual host %s document root %s is not accessible", vh.Name, vh.DocRoot)
+		}
+		if _, err := headers.New(headerRulesFrom(vh.HeaderRules)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
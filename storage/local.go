@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// Local is the storage of a directory of the local file system.
+type Local struct {
+	root string
+	dir  http.Dir
+}
+
+// NewLocal returns the storage of the root directory.
+func NewLocal(root string) *Local {
+	return &Local{root: root, dir: http.Dir(root)}
+}
+
+// Root returns the directory of the storage.
+func (l *Local) Root() string {
+	return l.root
+}
+
+// Path returns the path of the file in the local file system.
+func (l *Local) Path(name string) string {
+	return filepath.Join(l.root, filepath.FromSlash(Clean(name)))
+}
+
+func (l *Local) Open(name string) (http.File, error) {
+	return l.dir.Open(name)
+}
+
+func (l *Local) Stat(name string) (os.FileInfo, error) {
+	return os.Lstat(l.Path(name))
+}
+
+func (l *Local) List(name string) ([]os.FileInfo, error) {
+	return ioutil.ReadDir(l.Path(name))
+}
+
+// Create writes the content to a temporary file in the same
+// directory, which is renamed to the file once closed.
+func (l *Local) Create(name string, mode os.FileMode) (io.WriteCloser, error) {
+	path := l.Path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil { //nolint: gomnd
+		return nil, err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), ".goserve-create-*")
+	if err != nil {
+		return nil, err
+	}
+	return &localWriter{File: f, path: path, mode: mode}, nil
+}
+
+func (l *Local) MkdirAll(name string) error {
+	return os.MkdirAll(l.Path(name), 0755) //nolint: gomnd
+}
+
+func (l *Local) Rename(oldname, newname string) error {
+	path := l.Path(newname)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil { //nolint: gomnd
+		return err
+	}
+	return os.Rename(l.Path(oldname), path)
+}
+
+func (l *Local) Remove(name string) error {
+	return os.RemoveAll(l.Path(name))
+}
+
+// Symlink creates the symlink, replacing the file at name, if any.
+func (l *Local) Symlink(target, name string) error {
+	path := l.Path(name)
+	err := os.Remove(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return os.Symlink(target, path)
+}
+
+func (l *Local) Readlink(name string) (string, error) {
+	return os.Readlink(l.Path(name))
+}
+
+type localWriter struct {
+	*os.File
+	path string
+	mode os.FileMode
+}
+
+func (w *localWriter) Close() error {
+	err := w.File.Close()
+	if err == nil {
+		err = os.Chmod(w.Name(), w.mode)
+	}
+	if err == nil {
+		err = os.Rename(w.Name(), w.path)
+	}
+	if err != nil {
+		_ = os.Remove(w.Name())
+	}
+	return err
+}
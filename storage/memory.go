@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	stdpath "path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Memory is a storage that keeps the files in memory, mostly for
+// tests. Symlinks are not supported. It is safe for concurrent use.
+type Memory struct {
+	mu    sync.RWMutex
+	files map[string]*memoryFile
+}
+
+type memoryFile struct {
+	name    string
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// NewMemory returns an empty memory storage.
+func NewMemory() *Memory {
+	m := &Memory{files: map[string]*memoryFile{}}
+	m.files["/"] = &memoryFile{name: "/", mode: os.ModeDir | 0755, modTime: time.Now()}
+	return m
+}
+
+func (m *Memory) Open(name string) (http.File, error) {
+	name = Clean(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	f, ok := m.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	var children []os.FileInfo
+	if f.mode.IsDir() {
+		children = m.children(name)
+	}
+	return &memoryReader{Reader: bytes.NewReader(f.data), info: f.info(), children: children}, nil
+}
+
+func (m *Memory) Stat(name string) (os.FileInfo, error) {
+	name = Clean(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	f, ok := m.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return f.info(), nil
+}
+
+func (m *Memory) List(name string) ([]os.FileInfo, error) {
+	name = Clean(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	f, ok := m.files[name]
+	if !ok {
+		return nil, &os.PathError{Op: "list", Path: name, Err: os.ErrNotExist}
+	}
+	if !f.mode.IsDir() {
+		return nil, &os.PathError{Op: "list", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	return m.children(name), nil
+}
+
+// Create buffers the content in memory until the writer is closed.
+func (m *Memory) Create(name string, mode os.FileMode) (io.WriteCloser, error) {
+	name = Clean(name)
+	if name == "/" {
+		return nil, &os.PathError{Op: "create", Path: name, Err: os.ErrExist}
+	}
+	return &memoryWriter{m: m, name: name, mode: mode}, nil
+}
+
+func (m *Memory) MkdirAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.mkdirAll(Clean(name))
+}
+
+func (m *Memory) Rename(oldname, newname string) error {
+	oldname, newname = Clean(oldname), Clean(newname)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, ok := m.files[oldname]
+	if !ok || oldname == "/" {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrNotExist}
+	}
+	if dst, ok := m.files[newname]; ok && dst.mode.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrExist}
+	}
+	if f.mode.IsDir() && strings.HasPrefix(newname, oldname+"/") {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: os.ErrInvalid}
+	}
+	if err := m.mkdirAll(stdpath.Dir(newname)); err != nil {
+		return err
+	}
+	for name, f := range m.files {
+		if name != oldname && !strings.HasPrefix(name, oldname+"/") {
+			continue
+		}
+		delete(m.files, name)
+		moved := *f
+		moved.name = newname + strings.TrimPrefix(name, oldname)
+		m.files[moved.name] = &moved
+	}
+	return nil
+}
+
+func (m *Memory) Remove(name string) error {
+	name = Clean(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[name]; !ok || name == "/" {
+		return nil
+	}
+	for n := range m.files {
+		if n == name || strings.HasPrefix(n, name+"/") {
+			delete(m.files, n)
+		}
+	}
+	return nil
+}
+
+// mkdirAll creates the directory and its parents,
+// always with the mutex held.
+func (m *Memory) mkdirAll(name string) error {
+	if f, ok := m.files[name]; ok {
+		if !f.mode.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+		}
+		return nil
+	}
+	if err := m.mkdirAll(stdpath.Dir(name)); err != nil {
+		return err
+	}
+	m.files[name] = &memoryFile{name: name, mode: os.ModeDir | 0755, modTime: time.Now()}
+	return nil
+}
+
+// children returns the infos of the entries of the directory,
+// sorted by name, always with the mutex held.
+func (m *Memory) children(dir string) []os.FileInfo {
+	infos := []os.FileInfo{}
+	for name, f := range m.files {
+		if name != "/" && stdpath.Dir(name) == dir {
+			infos = append(infos, f.info())
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos
+}
+
+func (f *memoryFile) info() os.FileInfo {
+	return &memoryInfo{name: stdpath.Base(f.name), size: int64(len(f.data)), mode: f.mode, modTime: f.modTime}
+}
+
+type memoryInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i *memoryInfo) Name() string       { return i.name }
+func (i *memoryInfo) Size() int64        { return i.size }
+func (i *memoryInfo) Mode() os.FileMode  { return i.mode }
+func (i *memoryInfo) ModTime() time.Time { return i.modTime }
+func (i *memoryInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memoryInfo) Sys() interface{}   { return nil }
+
+type memoryReader struct {
+	*bytes.Reader
+	info     os.FileInfo
+	children []os.FileInfo
+}
+
+func (r *memoryReader) Close() error {
+	return nil
+}
+
+// Readdir follows the semantics of os.File.Readdir.
+func (r *memoryReader) Readdir(count int) ([]os.FileInfo, error) {
+	if !r.info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: r.info.Name(), Err: fmt.Errorf("not a directory")}
+	}
+	if count <= 0 {
+		infos := r.children
+		r.children = nil
+		return infos, nil
+	}
+	if len(r.children) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(r.children) {
+		count = len(r.children)
+	}
+	infos := r.children[:count]
+	r.children = r.children[count:]
+	return infos, nil
+}
+
+func (r *memoryReader) Stat() (os.FileInfo, error) {
+	return r.info, nil
+}
+
+type memoryWriter struct {
+	bytes.Buffer
+	m      *Memory
+	name   string
+	mode   os.FileMode
+	closed bool
+}
+
+func (w *memoryWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.m.mu.Lock()
+	defer w.m.mu.Unlock()
+	if f, ok := w.m.files[w.name]; ok && f.mode.IsDir() {
+		return &os.PathError{Op: "create", Path: w.name, Err: os.ErrExist}
+	}
+	if err := w.m.mkdirAll(stdpath.Dir(w.name)); err != nil {
+		return err
+	}
+	w.m.files[w.name] = &memoryFile{name: w.name, data: w.Bytes(), mode: w.mode.Perm(), modTime: time.Now()}
+	return nil
+}
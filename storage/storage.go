@@ -0,0 +1,90 @@
+// Package storage abstracts where the served and uploaded files are kept,
+// so the handlers work the same with the local file system and any other
+// backend.
+package storage
+
+import (
+	"io"
+	"net/http"
+	"os"
+	stdpath "path"
+	"path/filepath"
+)
+
+// Storage keeps the files of a document root. Names are slash separated
+// paths relative to its root, like the ones of http.FileSystem, which
+// every storage is. Missing files are reported with errors satisfying
+// os.IsNotExist.
+type Storage interface {
+	// Open opens the file or directory for reading. The order of the
+	// entries read from directories is not specified.
+	Open(name string) (http.File, error)
+	// Stat returns the info of the file, without following symlinks.
+	Stat(name string) (os.FileInfo, error)
+	// List returns the infos of the entries of the directory, sorted
+	// by name, without following symlinks.
+	List(name string) ([]os.FileInfo, error)
+	// Create returns a writer of the content of the file, creating its
+	// missing parent directories. The content replaces the previous one,
+	// if any, only once the writer is closed.
+	Create(name string, mode os.FileMode) (io.WriteCloser, error)
+	// MkdirAll creates the directory, along with its missing parents.
+	MkdirAll(name string) error
+	// Rename moves the file or directory, replacing the destination if
+	// it is a file, and creating its missing parent directories.
+	Rename(oldname, newname string) error
+	// Remove removes the file or directory, with all its contents.
+	Remove(name string) error
+}
+
+// Linker is implemented by the storages supporting symlinks.
+type Linker interface {
+	// Symlink creates the symlink to the target at name.
+	Symlink(target, name string) error
+	// Readlink returns the target of the symlink.
+	Readlink(name string) (string, error)
+}
+
+// Walk calls fn for the file at name and, if it is a directory, for all
+// the files under it, in lexical order, like filepath.Walk does. Returning
+// filepath.SkipDir from fn skips the directory. Symlinks are not followed.
+func Walk(s Storage, name string, fn func(name string, info os.FileInfo, err error) error) error {
+	name = Clean(name)
+	info, err := s.Stat(name)
+	if err != nil {
+		err = fn(name, nil, err)
+	} else {
+		err = walk(s, name, info, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walk(s Storage, name string, info os.FileInfo, fn func(name string, info os.FileInfo, err error) error) error {
+	if !info.IsDir() {
+		return fn(name, info, nil)
+	}
+	infos, err := s.List(name)
+	err1 := fn(name, info, err)
+	if err != nil || err1 != nil {
+		return err1
+	}
+	for _, child := range infos {
+		err := walk(s, stdpath.Join(name, child.Name()), child, fn)
+		switch {
+		case err == filepath.SkipDir && !child.IsDir():
+			return nil
+		case err != nil && err != filepath.SkipDir:
+			return err
+		}
+	}
+	return nil
+}
+
+// Clean returns the name as an absolute, slash separated path,
+// so it cannot refer to anything outside the storage root.
+func Clean(name string) string {
+	return stdpath.Clean("/" + name)
+}
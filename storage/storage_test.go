@@ -0,0 +1,150 @@
+// +build unit
+
+package storage_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/storage"
+)
+
+func storages(t *testing.T) map[string]storage.Storage {
+	return map[string]storage.Storage{
+		"local":  storage.NewLocal(t.TempDir()),
+		"memory": storage.NewMemory(),
+	}
+}
+
+func write(t *testing.T, s storage.Storage, name, content string) {
+	w, err := s.Create(name, 0644)
+	require.NoError(t, err)
+	_, err = io.WriteString(w, content)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+}
+
+func read(t *testing.T, s storage.Storage, name string) string {
+	f, err := s.Open(name)
+	require.NoError(t, err)
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func names(infos []os.FileInfo) []string {
+	result := make([]string, 0, len(infos))
+	for _, info := range infos {
+		result = append(result, info.Name())
+	}
+	return result
+}
+
+func TestStorage(t *testing.T) {
+	for name, s := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			w, err := s.Create("/docs/guide/intro.txt", 0644)
+			require.NoError(t, err)
+			_, err = io.WriteString(w, "intro")
+			require.NoError(t, err)
+			_, err = s.Stat("/docs/guide/intro.txt")
+			assert.True(t, os.IsNotExist(err), "content must not be visible until closed")
+			require.NoError(t, w.Close())
+			write(t, s, "docs/index.html", "index")
+			require.NoError(t, s.MkdirAll("/docs/empty"))
+
+			assert.Equal(t, "intro", read(t, s, "/docs/guide/intro.txt"))
+			info, err := s.Stat("/docs/index.html")
+			require.NoError(t, err)
+			assert.Equal(t, "index.html", info.Name())
+			assert.Equal(t, int64(5), info.Size())
+			assert.True(t, info.Mode().IsRegular())
+
+			infos, err := s.List("/docs")
+			require.NoError(t, err)
+			assert.Equal(t, []string{"empty", "guide", "index.html"}, names(infos))
+
+			dir, err := s.Open("/docs")
+			require.NoError(t, err)
+			infos, err = dir.Readdir(2)
+			require.NoError(t, err)
+			rest, err := dir.Readdir(-1)
+			require.NoError(t, err)
+			assert.Len(t, infos, 2)
+			assert.ElementsMatch(t, []string{"empty", "guide", "index.html"}, names(append(infos, rest...)))
+			require.NoError(t, dir.Close())
+
+			write(t, s, "/docs/index.html", "replaced")
+			assert.Equal(t, "replaced", read(t, s, "/docs/index.html"))
+
+			require.NoError(t, s.Rename("/docs/guide", "/v2/guide"))
+			assert.Equal(t, "intro", read(t, s, "/v2/guide/intro.txt"))
+			_, err = s.Stat("/docs/guide/intro.txt")
+			assert.True(t, os.IsNotExist(err))
+
+			require.NoError(t, s.Remove("/docs"))
+			_, err = s.Stat("/docs/index.html")
+			assert.True(t, os.IsNotExist(err))
+			_, err = s.Open("/docs")
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestStorageNamesCannotEscapeTheRoot(t *testing.T) {
+	for name, s := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			write(t, s, "../../escaped.txt", "escaped")
+			assert.Equal(t, "escaped", read(t, s, "/escaped.txt"))
+		})
+	}
+}
+
+func TestWalk(t *testing.T) {
+	for name, s := range storages(t) {
+		t.Run(name, func(t *testing.T) {
+			write(t, s, "/dir/a.txt", "a")
+			write(t, s, "/dir/skipped/b.txt", "b")
+			write(t, s, "/dir/z.txt", "z")
+
+			var walked []string
+			err := storage.Walk(s, "/dir", func(name string, info os.FileInfo, err error) error {
+				require.NoError(t, err)
+				walked = append(walked, name)
+				if info.Name() == "skipped" {
+					return filepath.SkipDir
+				}
+				return nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, []string{"/dir", "/dir/a.txt", "/dir/skipped", "/dir/z.txt"}, walked)
+
+			err = storage.Walk(s, "/missing", func(name string, info os.FileInfo, err error) error {
+				return err
+			})
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestLocalSymlinks(t *testing.T) {
+	root := t.TempDir()
+	s := storage.NewLocal(root)
+	write(t, s, "/a.txt", "a")
+	require.NoError(t, s.Symlink("a.txt", "/link"))
+	require.NoError(t, s.Symlink("a.txt", "/link"))
+
+	target, err := s.Readlink("/link")
+	require.NoError(t, err)
+	assert.Equal(t, "a.txt", target)
+	info, err := s.Stat("/link")
+	require.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink)
+	assert.Equal(t, filepath.Join(root, "link"), s.Path("/link"))
+}
@@ -0,0 +1,122 @@
+package symlink
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Policy decides which symlinks under a root directory can be followed.
+type Policy string
+
+const (
+	// Deny forbids following any symlink.
+	Deny Policy = "deny"
+	// Inside only allows following symlinks resolving inside the root.
+	Inside Policy = "inside"
+	// Allow allows following any symlink.
+	Allow Policy = "allow"
+)
+
+var ErrForbidden = errors.New("symlink not allowed")
+
+func (p Policy) Validate() error {
+	switch p {
+	case Deny, Inside, Allow:
+		return nil
+	default:
+		return fmt.Errorf("symlink: invalid policy %q", p)
+	}
+}
+
+// Guard enforces a symlink policy for the paths under a root directory.
+type Guard struct {
+	root   string
+	policy Policy
+}
+
+func NewGuard(root string, policy Policy) *Guard {
+	if abs, err := filepath.Abs(root); err == nil {
+		root = abs
+	}
+	return &Guard{root: filepath.Clean(root), policy: policy}
+}
+
+func (g *Guard) Policy() Policy {
+	return g.policy
+}
+
+// Check returns ErrForbidden if reaching the path, which must be inside
+// the root, requires following symlinks not allowed by the policy. Only
+// the existing part of the path is checked.
+func (g *Guard) Check(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	switch g.policy {
+	case Allow:
+		return nil
+	case Deny:
+		rel, err := filepath.Rel(g.root, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		current := g.root
+		for _, c := range strings.Split(rel, string(filepath.Separator)) {
+			current = filepath.Join(current, c)
+			info, err := os.Lstat(current)
+			if err != nil {
+				return nil
+			}
+			if info.Mode()&os.ModeSymlink != 0 {
+				return ErrForbidden
+			}
+		}
+		return nil
+	default:
+		existing := path
+		for !exists(existing) && existing != g.root {
+			existing = filepath.Dir(existing)
+		}
+		return g.inside(existing)
+	}
+}
+
+// Permits reports whether the symlink at path can be followed.
+func (g *Guard) Permits(path string) bool {
+	switch g.policy {
+	case Allow:
+		return true
+	case Deny:
+		return false
+	default:
+		return g.inside(path) == nil
+	}
+}
+
+func (g *Guard) inside(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	root, err := filepath.EvalSymlinks(g.root)
+	if err != nil {
+		return err
+	}
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return err
+	}
+	if resolved != root && !strings.HasPrefix(resolved, root+string(filepath.Separator)) {
+		return ErrForbidden
+	}
+	return nil
+}
+
+func exists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,58 @@
+// +build unit
+
+package symlink_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/symlink"
+)
+
+func tree(t *testing.T) (root, outside string) {
+	root, outside = t.TempDir(), t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "dir"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "dir", "file.txt"), nil, 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), nil, 0600))
+	require.NoError(t, os.Symlink("dir", filepath.Join(root, "alias")))
+	require.NoError(t, os.Symlink(outside, filepath.Join(root, "outside")))
+	return root, outside
+}
+
+func TestGuard(t *testing.T) {
+	root, _ := tree(t)
+	cases := []struct {
+		policy  symlink.Policy
+		path    string
+		allowed bool
+	}{
+		{symlink.Deny, "dir/file.txt", true},
+		{symlink.Deny, "alias/file.txt", false},
+		{symlink.Deny, "outside/secret.txt", false},
+		{symlink.Deny, "missing/file.txt", true},
+		{symlink.Inside, "dir/file.txt", true},
+		{symlink.Inside, "alias/file.txt", true},
+		{symlink.Inside, "outside/secret.txt", false},
+		{symlink.Inside, "outside/missing/new.txt", false},
+		{symlink.Inside, "dir/missing/new.txt", true},
+		{symlink.Allow, "outside/secret.txt", true},
+	}
+	for _, c := range cases {
+		err := symlink.NewGuard(root, c.policy).Check(filepath.Join(root, c.path))
+		assert.Equal(t, c.allowed, err == nil, "%s %s: %v", c.policy, c.path, err)
+	}
+
+	inside := symlink.NewGuard(root, symlink.Inside)
+	assert.True(t, inside.Permits(filepath.Join(root, "alias")))
+	assert.False(t, inside.Permits(filepath.Join(root, "outside")))
+	assert.False(t, symlink.NewGuard(root, symlink.Deny).Permits(filepath.Join(root, "alias")))
+}
+
+func TestPolicyValidate(t *testing.T) {
+	assert.NoError(t, symlink.Inside.Validate())
+	assert.Error(t, symlink.Policy("sometimes").Validate())
+}
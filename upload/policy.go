@@ -0,0 +1,172 @@
+package upload
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"go.eloylp.dev/go-serve/extract"
+)
+
+// sniffLen is the number of bytes http.DetectContentType considers.
+const sniffLen = 512
+
+// Rule restricts the files that can be uploaded under a path prefix.
+// Extensions are compared case insensitively. MIME types are sniffed
+// from the content of the files and can be patterns like "text/*".
+// Empty allow lists allow everything not explicitly denied.
+type Rule struct {
+	Prefix          string
+	AllowExtensions []string
+	DenyExtensions  []string
+	AllowMIMETypes  []string
+	DenyMIMETypes   []string
+}
+
+// Policy decides which files can be uploaded. Every file is checked
+// against the rule with the longest prefix that contains it. Files
+// not covered by any rule are allowed.
+type Policy struct {
+	rules []Rule
+}
+
+func NewPolicy(rules []Rule) *Policy {
+	sorted := make([]Rule, len(rules))
+	copy(sorted, rules)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].Prefix) > len(sorted[j].Prefix)
+	})
+	return &Policy{rules: sorted}
+}
+
+// Violation describes a file rejected by the policy.
+type Violation struct {
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+// PolicyError is returned when an upload contains files
+// rejected by the policy.
+type PolicyError struct {
+	Violations []Violation
+}
+
+func (e *PolicyError) Error() string {
+	paths := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		paths = append(paths, v.Path)
+	}
+	return fmt.Sprintf("upload policy violation: %s", strings.Join(paths, ", "))
+}
+
+// Verify checks the files written in dir, which will be placed at root,
+// a path relative to the document root. All the violations are returned
+// as a *PolicyError, so the whole upload can be rejected at once.
+func (p *Policy) Verify(dir, root string, files []extract.File) error {
+	var violations []Violation
+	for _, f := range files {
+		target := path.Join("/", root, f.Path)
+		head, err := sniff(filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if err != nil {
+			return err
+		}
+		if reason := p.check(target, head); reason != "" {
+			violations = append(violations, Violation{Path: target, Reason: reason})
+		}
+	}
+	if len(violations) > 0 {
+		return &PolicyError{Violations: violations}
+	}
+	return nil
+}
+
+// check returns the reason why the file at target, starting with the
+// head bytes, is not allowed. A nil head skips the MIME type checks.
+func (p *Policy) check(target string, head []byte) string {
+	r, ok := p.rule(target)
+	if !ok {
+		return ""
+	}
+	ext := strings.ToLower(path.Ext(target))
+	if containsExtension(r.DenyExtensions, ext) {
+		return fmt.Sprintf("extension %q is denied", ext)
+	}
+	if len(r.AllowExtensions) > 0 && !containsExtension(r.AllowExtensions, ext) {
+		return fmt.Sprintf("extension %q is not allowed", ext)
+	}
+	if head == nil {
+		return ""
+	}
+	mime := http.DetectContentType(head)
+	if i := strings.Index(mime, ";"); i >= 0 {
+		mime = mime[:i]
+	}
+	if matchesMIME(r.DenyMIMETypes, mime) {
+		return fmt.Sprintf("content type %q is denied", mime)
+	}
+	if len(r.AllowMIMETypes) > 0 && !matchesMIME(r.AllowMIMETypes, mime) {
+		return fmt.Sprintf("content type %q is not allowed", mime)
+	}
+	return ""
+}
+
+func (p *Policy) rule(target string) (Rule, bool) {
+	for _, r := range p.rules {
+		prefix := strings.TrimSuffix(r.Prefix, "/")
+		if target == prefix || strings.HasPrefix(target, prefix+"/") {
+			return r, true
+		}
+	}
+	return Rule{}, false
+}
+
+// sniff returns the first bytes of a regular file, or nil for
+// other kinds of files, like symlinks.
+func sniff(name string) ([]byte, error) {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	return head[:n], nil
+}
+
+func containsExtension(list []string, ext string) bool {
+	for _, e := range list {
+		e = strings.ToLower(e)
+		if !strings.HasPrefix(e, ".") && e != "" {
+			e = "." + e
+		}
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesMIME(patterns []string, mime string) bool {
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if p == "*/*" || p == mime || (strings.HasSuffix(p, "/*") && strings.HasPrefix(mime, strings.TrimSuffix(p, "*"))) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,38 @@
+// +build unit
+
+//nolint:testpackage
+package upload
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyCheck(t *testing.T) {
+	p := NewPolicy([]Rule{
+		{Prefix: "/", DenyExtensions: []string{".html", "JS"}},
+		{Prefix: "/site/", AllowExtensions: []string{".html", ".css"}, DenyMIMETypes: []string{"application/*"}},
+		{Prefix: "/images", AllowMIMETypes: []string{"image/*"}},
+	})
+	png := []byte("\x89PNG\x0D\x0A\x1A\x0A")
+	cases := []struct {
+		target string
+		head   []byte
+		reason string
+	}{
+		{"/notes.txt", []byte("hello"), ""},
+		{"/index.html", []byte("<html>"), `extension ".html" is denied`},
+		{"/app.Js", nil, `extension ".js" is denied`},
+		{"/site/index.html", []byte("<html>"), ""},
+		{"/site/app.js", []byte("alert()"), `extension ".js" is not allowed`},
+		{"/site/zip.css", []byte("PK\x03\x04"), `content type "application/zip" is denied`},
+		{"/sites/index.html", []byte("<html>"), `extension ".html" is denied`},
+		{"/images/tux.png", png, ""},
+		{"/images/tux.png", []byte("<html>"), `content type "text/html" is not allowed`},
+		{"/images/link.png", nil, ""},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.reason, p.check(c.target, c.head), c.target)
+	}
+}
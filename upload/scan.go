@@ -0,0 +1,85 @@
+package upload
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"go.eloylp.dev/go-serve/extract"
+	"go.eloylp.dev/go-serve/metrics"
+	"go.eloylp.dev/go-serve/scan"
+)
+
+// Infection describes a file flagged by the malware scanner.
+type Infection struct {
+	Path      string `json:"path"`
+	Signature string `json:"signature"`
+}
+
+// MalwareError is returned when an upload contains files
+// flagged by the malware scanner.
+type MalwareError struct {
+	Infections []Infection
+}
+
+func (e *MalwareError) Error() string {
+	found := make([]string, 0, len(e.Infections))
+	for _, i := range e.Infections {
+		found = append(found, fmt.Sprintf("%s (%s)", i.Path, i.Signature))
+	}
+	return fmt.Sprintf("malware detected: %s", strings.Join(found, ", "))
+}
+
+// Scan scans the regular files written in dir, which will be placed at
+// root, a path relative to the document root. Infected files are returned
+// as a *MalwareError. Any other error means no verdict could be reached.
+func Scan(ctx context.Context, scanner scan.Scanner, dir, root string, files []extract.File) error {
+	var infections []Infection
+	for _, f := range files {
+		result, err := scanFile(ctx, scanner, filepath.Join(dir, filepath.FromSlash(f.Path)))
+		if err != nil {
+			observeScan(ctx, "error")
+			return fmt.Errorf("scanning %s: %w", f.Path, err)
+		}
+		if result == nil {
+			continue
+		}
+		if !result.Infected {
+			observeScan(ctx, "clean")
+			continue
+		}
+		observeScan(ctx, "infected")
+		infections = append(infections, Infection{Path: path.Join("/", root, f.Path), Signature: result.Signature})
+	}
+	if len(infections) > 0 {
+		return &MalwareError{Infections: infections}
+	}
+	return nil
+}
+
+func observeScan(ctx context.Context, result string) {
+	if metrics.UploadScans != nil {
+		metrics.UploadScans.WithLabelValues(metrics.Labels(ctx, result)...).Inc()
+	}
+}
+
+// scanFile returns a nil result for files that are not regular,
+// like symlinks, as they have no contents of their own.
+func scanFile(ctx context.Context, scanner scan.Scanner, name string) (*scan.Result, error) {
+	info, err := os.Lstat(name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, nil
+	}
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return scanner.Scan(ctx, file)
+}
@@ -0,0 +1,119 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"syscall"
+
+	"go.eloylp.dev/go-serve/extract"
+	"go.eloylp.dev/go-serve/storage"
+)
+
+// Stage is a temporary directory where uploads are written before
+// being moved to their final destination. This way, failed or rejected
+// uploads never become visible in the document root.
+type Stage struct {
+	dir string
+}
+
+// NewStage creates a stage inside the parent directory, or in the
+// default directory for temporary files if parent is empty. Placing it
+// in the same file system as the document root makes commits atomic
+// per file, as files are renamed instead of copied.
+func NewStage(parent string) (*Stage, error) {
+	dir, err := os.MkdirTemp(parent, "goserve-upload-")
+	if err != nil {
+		return nil, fmt.Errorf("stage: %w", err)
+	}
+	return &Stage{dir: dir}, nil
+}
+
+func (s *Stage) Dir() string {
+	return s.dir
+}
+
+// Commit moves the staged contents to the destination directory of the
+// storage, creating it if needed. It flags the files of the report that
+// overwrote existing ones. The check function, if not nil, can veto the
+// directories the contents are moved to, like the ones reached through
+// symlinks. Files are renamed into local storages, when possible.
+func (s *Stage) Commit(dst storage.Storage, dir string, report *extract.Report, check func(name string) error) error {
+	overwritten := map[string]bool{}
+	err := filepath.Walk(s.dir, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.dir, name)
+		if err != nil {
+			return err
+		}
+		target := path.Join(storage.Clean(dir), filepath.ToSlash(rel))
+		if info.IsDir() {
+			if check != nil {
+				if err := check(target); err != nil {
+					return err
+				}
+			}
+			return dst.MkdirAll(target)
+		}
+		_, err = dst.Stat(target)
+		overwritten[filepath.ToSlash(rel)] = err == nil
+		return move(dst, name, target, info)
+	})
+	if err != nil {
+		return fmt.Errorf("stage: %w", err)
+	}
+	for i := range report.Files {
+		report.Files[i].Overwritten = overwritten[path.Clean(report.Files[i].Path)]
+	}
+	return nil
+}
+
+// Remove deletes the stage and whatever is left in it.
+func (s *Stage) Remove() error {
+	return os.RemoveAll(s.dir)
+}
+
+// move renames the file into local storages, falling back to a
+// copy when the storage is not local or in another file system.
+func move(dst storage.Storage, src, name string, info os.FileInfo) error {
+	if local, ok := dst.(*storage.Local); ok {
+		err := os.Rename(src, local.Path(name))
+		if err == nil || !errors.Is(err, syscall.EXDEV) {
+			return err
+		}
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		linker, ok := dst.(storage.Linker)
+		if !ok {
+			return fmt.Errorf("symlink %s not supported by the storage", name)
+		}
+		link, err := os.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return linker.Symlink(link, name)
+	}
+	return copyFile(dst, src, name, info.Mode().Perm())
+}
+
+func copyFile(dst storage.Storage, src, name string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := dst.Create(name, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,61 @@
+// +build unit
+
+package upload_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.eloylp.dev/go-serve/extract"
+	"go.eloylp.dev/go-serve/storage"
+	"go.eloylp.dev/go-serve/upload"
+)
+
+func TestStageCommit(t *testing.T) {
+	dst := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dst, "a.txt"), []byte("old"), 0600))
+
+	stage, err := upload.NewStage(t.TempDir())
+	require.NoError(t, err)
+	defer stage.Remove()
+	require.NoError(t, os.WriteFile(filepath.Join(stage.Dir(), "a.txt"), []byte("new"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(stage.Dir(), "dir", "empty"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(stage.Dir(), "dir", "b.txt"), []byte("b"), 0600))
+
+	report := &extract.Report{Files: []extract.File{{Path: "./a.txt"}, {Path: "dir/b.txt"}}}
+	require.NoError(t, stage.Commit(storage.NewLocal(dst), "/", report, nil))
+
+	assert.True(t, report.Files[0].Overwritten)
+	assert.False(t, report.Files[1].Overwritten)
+	data, err := os.ReadFile(filepath.Join(dst, "a.txt"))
+	require.NoError(t, err)
+	assert.Equal(t, "new", string(data))
+	assert.FileExists(t, filepath.Join(dst, "dir", "b.txt"))
+	assert.DirExists(t, filepath.Join(dst, "dir", "empty"))
+
+	require.NoError(t, stage.Remove())
+	assert.NoDirExists(t, stage.Dir())
+}
+
+func TestStageCommitToMemory(t *testing.T) {
+	dst := storage.NewMemory()
+
+	stage, err := upload.NewStage(t.TempDir())
+	require.NoError(t, err)
+	defer stage.Remove()
+	require.NoError(t, os.WriteFile(filepath.Join(stage.Dir(), "a.txt"), []byte("a"), 0600))
+	require.NoError(t, os.Symlink("a.txt", filepath.Join(stage.Dir(), "link")))
+
+	report := &extract.Report{Files: []extract.File{{Path: "a.txt"}}}
+	assert.Error(t, stage.Commit(dst, "/docs", report, nil), "symlinks are not supported by memory storages")
+
+	require.NoError(t, os.Remove(filepath.Join(stage.Dir(), "link")))
+	require.NoError(t, stage.Commit(dst, "/docs", report, nil))
+	info, err := dst.Stat("/docs/a.txt")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), info.Size())
+}